@@ -0,0 +1,228 @@
+/*
+ * Copyright 2018-2024 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rustup
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/Masterminds/semver/v3"
+	"github.com/buildpacks/libcnb"
+	"github.com/paketo-buildpacks/libpak"
+	"github.com/paketo-buildpacks/libpak/bindings"
+)
+
+// AdvisoryBindingType is the type of a binding containing a RustSec advisory database
+const AdvisoryBindingType = "rustsec-advisory-db"
+
+// Advisory is a RustSec advisory for a part of the Rust toolchain, like `std` or `cargo`
+type Advisory struct {
+	ID         string
+	Package    string
+	Date       string
+	URL        string
+	Title      string
+	Aliases    []string
+	Withdrawn  string
+	Patched    []string
+	Unaffected []string
+}
+
+type advisoryFile struct {
+	Advisory struct {
+		ID        string   `toml:"id"`
+		Package   string   `toml:"package"`
+		Date      string   `toml:"date"`
+		URL       string   `toml:"url"`
+		Aliases   []string `toml:"aliases"`
+		Withdrawn string   `toml:"withdrawn"`
+	} `toml:"advisory"`
+	Versions struct {
+		Patched    []string `toml:"patched"`
+		Unaffected []string `toml:"unaffected"`
+	} `toml:"versions"`
+}
+
+// ResolveAdvisoryDatabase returns the directory of the RustSec advisory database configured with
+// $BP_RUST_ADVISORY_DB, relative to the application, or the path of a binding of type `rustsec-advisory-db`. It
+// returns false if neither is configured.
+func ResolveAdvisoryDatabase(cr libpak.ConfigurationResolver, binds libcnb.Bindings, appPath string) (string, bool, error) {
+	path, _ := cr.Resolve("BP_RUST_ADVISORY_DB")
+	if path != "" {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(appPath, path)
+		}
+
+		if fi, err := os.Stat(path); err != nil {
+			return "", false, fmt.Errorf("unable to find advisory database %s\n%w", path, err)
+		} else if !fi.IsDir() {
+			return "", false, fmt.Errorf("advisory database %s is not a directory", path)
+		}
+
+		return path, true, nil
+	}
+
+	binding, ok, err := bindings.ResolveOne(binds, bindings.OfType(AdvisoryBindingType))
+	if err != nil {
+		return "", false, fmt.Errorf("unable to resolve binding\n%w", err)
+	}
+	if !ok {
+		return "", false, nil
+	}
+
+	return binding.Path, true, nil
+}
+
+// ReadAdvisories reads the toolchain advisories of a RustSec advisory database. These are the files in the `rust`
+// directory, like `rust/std/CVE-2022-21658.md`. A directory without a `rust` directory, like a binding, is read as a
+// flat list of advisories. Withdrawn advisories are skipped, as are the `..data` and `..<timestamp>` directories of
+// Kubernetes bindings, which hold a second copy of each key.
+func ReadAdvisories(path string) ([]Advisory, error) {
+	root := filepath.Join(path, "rust")
+	if _, err := os.Stat(root); errors.Is(err, fs.ErrNotExist) {
+		root = path
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to stat %s\n%w", root, err)
+	}
+
+	var advisories []Advisory
+	seen := map[string]bool{}
+	if err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p != root && strings.HasPrefix(d.Name(), "..") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || filepath.Ext(p) != ".md" {
+			return nil
+		}
+
+		a, ok, err := ReadAdvisory(p)
+		if err != nil {
+			return err
+		}
+		if ok && a.Withdrawn == "" && !seen[a.ID] {
+			seen[a.ID] = true
+			advisories = append(advisories, a)
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("unable to read advisories in %s\n%w", root, err)
+	}
+
+	sort.Slice(advisories, func(i, j int) bool {
+		return advisories[i].ID < advisories[j].ID
+	})
+
+	return advisories, nil
+}
+
+// ReadAdvisory reads a RustSec advisory, which is Markdown starting with a TOML code block. It returns false for
+// Markdown without the code block.
+func ReadAdvisory(path string) (Advisory, bool, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return Advisory{}, false, fmt.Errorf("unable to read %s\n%w", path, err)
+	}
+
+	raw = bytes.ReplaceAll(raw, []byte("\r\n"), []byte("\n"))
+	if !bytes.HasPrefix(raw, []byte("```toml\n")) {
+		return Advisory{}, false, nil
+	}
+
+	frontMatter, body, ok := bytes.Cut(bytes.TrimPrefix(raw, []byte("```toml\n")), []byte("\n```"))
+	if !ok {
+		return Advisory{}, false, fmt.Errorf("unable to find the end of the TOML block in %s", path)
+	}
+
+	var f advisoryFile
+	if _, err := toml.Decode(string(frontMatter), &f); err != nil {
+		return Advisory{}, false, fmt.Errorf("unable to decode %s\n%w", path, err)
+	}
+	if f.Advisory.ID == "" {
+		return Advisory{}, false, fmt.Errorf("advisory %s has no id", path)
+	}
+
+	a := Advisory{
+		ID:         f.Advisory.ID,
+		Package:    f.Advisory.Package,
+		Date:       f.Advisory.Date,
+		URL:        f.Advisory.URL,
+		Aliases:    f.Advisory.Aliases,
+		Withdrawn:  f.Advisory.Withdrawn,
+		Patched:    f.Versions.Patched,
+		Unaffected: f.Versions.Unaffected,
+	}
+
+	for _, line := range strings.Split(string(body), "\n") {
+		if strings.HasPrefix(line, "# ") {
+			a.Title = strings.TrimSpace(strings.TrimPrefix(line, "# "))
+			break
+		}
+	}
+
+	return a, true, nil
+}
+
+// Affects returns true if a Rust version is not matched by any of the patched or unaffected version requirements.
+// Pre-release versions, like `1.80.0-nightly`, are compared by their release version.
+func (a Advisory) Affects(version string) (bool, error) {
+	v, err := semver.NewVersion(version)
+	if err != nil {
+		return false, fmt.Errorf("unable to parse version %s\n%w", version, err)
+	}
+
+	release, err := v.SetPrerelease("")
+	if err != nil {
+		return false, fmt.Errorf("unable to remove pre-release from %s\n%w", version, err)
+	}
+
+	for _, req := range append(append([]string{}, a.Patched...), a.Unaffected...) {
+		c, err := semver.NewConstraint(req)
+		if err != nil {
+			return false, fmt.Errorf("unable to parse version requirement %q of %s\n%w", req, a.ID, err)
+		}
+
+		if c.Check(&release) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+func (a Advisory) String() string {
+	s := a.ID
+	if a.Title != "" {
+		s = fmt.Sprintf("%s: %s", s, a.Title)
+	}
+	if a.URL != "" {
+		s = fmt.Sprintf("%s (%s)", s, a.URL)
+	}
+	return s
+}
@@ -0,0 +1,137 @@
+/*
+ * Copyright 2018-2024 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rustup_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/paketo-community/rustup/rustup"
+	"github.com/sclevine/spec"
+)
+
+func testAdvisory(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+	)
+
+	it("reads the toolchain advisories of a database", func() {
+		advisories, err := rustup.ReadAdvisories(filepath.Join("testdata", "advisory-db"))
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(advisories).To(HaveLen(2))
+		Expect(advisories[0]).To(Equal(rustup.Advisory{
+			ID:         "CVE-2022-21658",
+			Package:    "std",
+			Date:       "2022-01-20",
+			URL:        "https://groups.google.com/g/rustlang-security-announcements/c/R1fZFDhnJVQ",
+			Title:      "Race condition in std::fs::remove_dir_all",
+			Aliases:    []string{"GHSA-r9cc-f5pr-p3j2"},
+			Patched:    []string{">= 1.58.1"},
+			Unaffected: []string{"< 1.0.0"},
+		}))
+		Expect(advisories[1].ID).To(Equal("CVE-2024-99999"))
+	})
+
+	it("reads a flat directory of advisories", func() {
+		advisories, err := rustup.ReadAdvisories(filepath.Join("testdata", "advisory-db", "rust", "std"))
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(advisories).To(HaveLen(1))
+		Expect(advisories[0].ID).To(Equal("CVE-2022-21658"))
+	})
+
+	it("skips the internal directories of Kubernetes bindings", func() {
+		raw, err := os.ReadFile(filepath.Join("testdata", "advisory-db", "rust", "std", "CVE-2022-21658.md"))
+		Expect(err).NotTo(HaveOccurred())
+
+		dir := t.TempDir()
+		Expect(os.MkdirAll(filepath.Join(dir, "..2024_05_02_00_00_00.000000000"), 0755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, "..2024_05_02_00_00_00.000000000", "CVE-2022-21658.md"), raw, 0644)).To(Succeed())
+		Expect(os.Symlink("..2024_05_02_00_00_00.000000000", filepath.Join(dir, "..data"))).To(Succeed())
+		Expect(os.Symlink(filepath.Join("..data", "CVE-2022-21658.md"), filepath.Join(dir, "CVE-2022-21658.md"))).To(Succeed())
+
+		advisories, err := rustup.ReadAdvisories(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(advisories).To(HaveLen(1))
+		Expect(advisories[0].ID).To(Equal("CVE-2022-21658"))
+	})
+
+	it("reads each advisory once", func() {
+		raw, err := os.ReadFile(filepath.Join("testdata", "advisory-db", "rust", "std", "CVE-2022-21658.md"))
+		Expect(err).NotTo(HaveOccurred())
+
+		dir := t.TempDir()
+		Expect(os.WriteFile(filepath.Join(dir, "CVE-2022-21658.md"), raw, 0644)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, "RUSTSEC-2022-0000.md"), raw, 0644)).To(Succeed())
+
+		advisories, err := rustup.ReadAdvisories(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(advisories).To(HaveLen(1))
+	})
+
+	it("skips Markdown without an advisory", func() {
+		_, ok, err := rustup.ReadAdvisory(filepath.Join("testdata", "advisory-db", "README.md"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeFalse())
+	})
+
+	it("fails on an unterminated TOML block", func() {
+		dir := t.TempDir()
+		Expect(os.WriteFile(filepath.Join(dir, "CVE-0000-0000.md"), []byte("```toml\n[advisory]\nid = \"x\"\n"), 0644)).To(Succeed())
+
+		_, err := rustup.ReadAdvisories(dir)
+		Expect(err).To(MatchError(ContainSubstring("unable to find the end of the TOML block")))
+	})
+
+	context("Affects", func() {
+		advisory := rustup.Advisory{
+			ID:         "CVE-2022-21658",
+			Patched:    []string{">= 1.58.1"},
+			Unaffected: []string{"< 1.0.0"},
+		}
+
+		it("affects versions before the patch", func() {
+			Expect(advisory.Affects("1.58.0")).To(BeTrue())
+		})
+
+		it("does not affect patched versions", func() {
+			Expect(advisory.Affects("1.58.1")).To(BeFalse())
+			Expect(advisory.Affects("1.78.0")).To(BeFalse())
+		})
+
+		it("does not affect unaffected versions", func() {
+			Expect(advisory.Affects("0.12.0")).To(BeFalse())
+		})
+
+		it("compares pre-release versions by their release", func() {
+			Expect(advisory.Affects("1.57.0-nightly")).To(BeTrue())
+			Expect(advisory.Affects("1.60.0-beta.1")).To(BeFalse())
+		})
+
+		it("affects all versions without requirements", func() {
+			Expect(rustup.Advisory{ID: "CVE-0000-0000"}.Affects("1.78.0")).To(BeTrue())
+		})
+
+		it("fails on an invalid requirement", func() {
+			_, err := rustup.Advisory{ID: "CVE-0000-0000", Patched: []string{"not a version"}}.Affects("1.78.0")
+			Expect(err).To(MatchError(ContainSubstring("unable to parse version requirement")))
+		})
+	})
+}
@@ -17,20 +17,23 @@
 package rustup
 
 import (
-	"errors"
 	"fmt"
-	"io/fs"
-	"os"
 	"path/filepath"
-	"runtime"
+	"regexp"
+	"strings"
+	"unicode"
 
 	"github.com/buildpacks/libcnb"
+	"github.com/heroku/color"
 	"github.com/paketo-buildpacks/libpak"
 	"github.com/paketo-buildpacks/libpak/bard"
 )
 
 type Build struct {
 	Logger bard.Logger
+
+	// LibcDetector detects the libc of the build image for BP_RUSTUP_INIT_LIBC=auto
+	LibcDetector LibcDetector
 }
 
 func (b Build) Build(context libcnb.BuildContext) (libcnb.BuildResult, error) {
@@ -65,9 +68,29 @@ func (b Build) Build(context libcnb.BuildContext) (libcnb.BuildResult, error) {
 			return libcnb.BuildResult{}, fmt.Errorf("unable to create dependency resolver\n%w", err)
 		}
 
+		// trust the CAs from bindings for everything downloaded by rustup and cargo
+		certificates, err := ResolveCACertificates(context.Platform.Bindings)
+		if err != nil {
+			return libcnb.BuildResult{}, fmt.Errorf("unable to resolve CA certificates\n%w", err)
+		}
+		if len(certificates) > 0 {
+			caCertificates := NewCACertificates(certificates)
+			caCertificates.Logger = b.Logger
+			result.Layers = append(result.Layers, caCertificates)
+		}
+
 		// install rustup-init
 		v, _ := cr.Resolve("BP_RUSTUP_INIT_VERSION")
 		libc, _ := cr.Resolve("BP_RUSTUP_INIT_LIBC")
+		if libc == LibcAuto {
+			var reason string
+			if libc, reason, err = b.LibcDetector.Detect(); err != nil {
+				return libcnb.BuildResult{}, fmt.Errorf("unable to detect libc\n%w", err)
+			}
+
+			b.Logger.Header("rustup-init libc")
+			b.Logger.Bodyf("Using %s libc, %s. Set BP_RUSTUP_INIT_LIBC to gnu or musl to override", libc, reason)
+		}
 
 		rustupInitDependency, err := dr.Resolve(fmt.Sprintf("rustup-init-%s", libc), v)
 		if err != nil {
@@ -79,28 +102,274 @@ func (b Build) Build(context libcnb.BuildContext) (libcnb.BuildResult, error) {
 
 		result.Layers = append(result.Layers, rustupInit)
 
+		registries, err := ResolveCargoRegistries(context.Platform.Bindings)
+		if err != nil {
+			return libcnb.BuildResult{}, fmt.Errorf("unable to resolve cargo registries\n%w", err)
+		}
+
+		sources, err := ResolveCargoSources(cr, context.Application.Path)
+		if err != nil {
+			return libcnb.BuildResult{}, fmt.Errorf("unable to resolve cargo sources\n%w", err)
+		}
+
+		appConfig, appSources, err := ReadAppCargoSources(context.Application.Path)
+		if err != nil {
+			return libcnb.BuildResult{}, fmt.Errorf("unable to read application cargo configuration\n%w", err)
+		}
+
+		if replaceWith := appSources[CratesIOSource].ReplaceWith; replaceWith != "" && sources != nil &&
+			replaceWith != sources[CratesIOSource].ReplaceWith {
+			b.Logger.Bodyf("%s %s replaces %s with %s, which takes precedence over the configured source replacement",
+				color.YellowString("Warning:"), appConfig, CratesIOSource, replaceWith)
+		}
+
+		if effective := EffectiveCargoSources(sources, appSources); len(effective) > 0 {
+			b.Logger.Header("Cargo sources")
+			for _, name := range SortedSourceNames(effective) {
+				b.Logger.Bodyf("%s %s", name, effective[name])
+			}
+		}
+
 		// make layer for cargo, which is installed by rust
-		cargo := Cargo{}
+		cargo := Cargo{Registries: registries, Sources: sources}
 		cargo.Logger = b.Logger
 		result.Layers = append(result.Layers, cargo)
 
+		// tokens are kept out of the cached cargo layer
+		for _, r := range registries {
+			if r.Token != "" {
+				result.Layers = append(result.Layers, CargoCredentials{Logger: b.Logger, Registries: registries})
+				break
+			}
+		}
+
+		mirror, err := ResolveMirror(cr, context.Platform.Bindings)
+		if err != nil {
+			return libcnb.BuildResult{}, fmt.Errorf("unable to resolve rustup mirror\n%w", err)
+		}
+		// toolchains packaged with the buildpack are installed without network access, unless a mirror is configured
+		var distDependencies []libpak.BuildpackDependency
+		if mirror.DistServer == "" {
+			distDependencies, err = ResolveRustDistDependencies(dr)
+			if err != nil {
+				return libcnb.BuildResult{}, fmt.Errorf("unable to resolve packaged toolchains\n%w", err)
+			}
+
+			if len(distDependencies) > 0 {
+				rustDist := NewRustDist(distDependencies, dc)
+				rustDist.Logger = b.Logger
+				result.Layers = append(result.Layers, rustDist)
+
+				mirror.DistServer = rustDist.DistServer(context.Layers.Path)
+			}
+		}
+
+		if mirror.IsSet() {
+			b.Logger.Header("Rust distribution mirror")
+			b.Logger.Body(mirror.String())
+		}
+
 		// install rustup
 		profile, profileSet := cr.Resolve("BP_RUST_PROFILE")
 		rustup := NewRustup(rustupInitDependency.Version, profile)
 		rustup.Logger = b.Logger
+		rustup.Mirror = mirror
 
 		result.Layers = append(result.Layers, rustup)
 
 		// install rust
-		rustToolChainFilePath, err := rustToolChainFilePath(context.Application.Path)
+		toolchainFiles, err := ReadToolchainFiles(context.Application.Path)
 		if err != nil {
-			return libcnb.BuildResult{}, fmt.Errorf("unable to find dependency\n%w", err)
+			return libcnb.BuildResult{}, fmt.Errorf("unable to parse rust toolchain file\n%w", err)
+		}
+
+		var toolchainFile ToolchainFile
+		if len(toolchainFiles) > 0 {
+			toolchainFile = toolchainFiles[0]
+
+			b.Logger.Headerf("Rust toolchain file %s", filepath.Base(toolchainFile.Path))
+			b.Logger.Body(toolchainFile.String())
+
+			for _, f := range toolchainFiles[1:] {
+				if f.Name() != toolchainFile.Name() {
+					b.Logger.Bodyf("%s %s requests %s, but %s takes precedence with %s", color.YellowString("Warning:"),
+						filepath.Base(f.Path), f.Name(), filepath.Base(toolchainFile.Path), toolchainFile.Name())
+				} else {
+					b.Logger.Bodyf("%s also requests %s", filepath.Base(f.Path), f.Name())
+				}
+			}
+		}
+
+		val, _ := cr.Resolve("BP_RUST_TOOLCHAIN_LOCK")
+		lockMode, err := ParseLockMode(val)
+		if err != nil {
+			return libcnb.BuildResult{}, fmt.Errorf("unable to resolve toolchain lock mode\n%w", err)
+		}
+
+		lockPath := filepath.Join(context.Application.Path, LockFileName)
+		var lock ToolchainLock
+		if lockMode != LockModeOff {
+			var ok bool
+			lock, ok, err = ReadToolchainLock(lockPath)
+			if err != nil {
+				return libcnb.BuildResult{}, fmt.Errorf("unable to read toolchain lock\n%w", err)
+			}
+
+			if lockMode == LockModeAuto {
+				lockMode = LockModeWrite
+				if ok {
+					lockMode = LockModeRead
+				}
+			} else if lockMode == LockModeRead && !ok {
+				return libcnb.BuildResult{}, fmt.Errorf("unable to find %s, build with BP_RUST_TOOLCHAIN_LOCK=write to create it", LockFileName)
+			}
 		}
 
 		rustVersion, rustVersionSet := cr.Resolve("BP_RUST_TOOLCHAIN")
-		additionalTarget := AdditionalTarget(cr, context.StackID)
-		rust := NewRust(profile, rustVersion, additionalTarget, rustToolChainFilePath, profileSet, rustVersionSet)
+
+		// the toolchain requested by other buildpacks is the default, unless the application configures one
+		planToolchain, err := PlanToolchain(context.Plan.Entries)
+		if err != nil {
+			return libcnb.BuildResult{}, fmt.Errorf("unable to resolve build plan toolchain\n%w", err)
+		}
+		planDefault := planToolchain.Name != "" && !toolchainFile.Exists() && !rustVersionSet
+		if planDefault {
+			rustVersion = planToolchain.Name
+		}
+
+		// the lock file is read before version ranges are resolved, so that a locked range is not resolved again
+		rangeResolver := &RangeResolver{
+			Logger:       b.Logger,
+			Index:        ReleaseIndexLocation(cr, mirror, context.Application.Path),
+			Packaged:     distDependencies,
+			Certificates: certificates,
+		}
+		if lockMode == LockModeRead {
+			rangeResolver.Lock = lock
+		}
+
+		rustVersion, rustVersionRange, err := b.resolveToolchain(rustVersion, context.Application.Path, rangeResolver)
+		if err != nil {
+			return libcnb.BuildResult{}, err
+		}
+
+		if planDefault {
+			planToolchain.Name, planToolchain.Range = rustVersion, rustVersionRange
+		} else if planToolchain.Name != "" {
+			planToolchain.Name, planToolchain.Range, err = b.resolveToolchain(planToolchain.Name, context.Application.Path,
+				rangeResolver)
+			if err != nil {
+				return libcnb.BuildResult{}, err
+			}
+		}
+
+		additionalTargets, err := AdditionalTargets(b.Logger, cr, context.StackID, libc)
+		if err != nil {
+			return libcnb.BuildResult{}, fmt.Errorf("unable to resolve additional targets\n%w", err)
+		}
+
+		val, _ = cr.Resolve("BP_RUST_COMPONENTS")
+		components, err := ParseComponents(val)
+		if err != nil {
+			return libcnb.BuildResult{}, fmt.Errorf("unable to resolve components\n%w", err)
+		}
+
+		var toolchains []Toolchain
+		if toolchainFile.Exists() {
+			toolchains = AppendToolchain(toolchains, toolchainFile.Toolchain(profile, rustVersion))
+		}
+		if !toolchainFile.Exists() || profileSet || rustVersionSet {
+			toolchains = AppendToolchain(toolchains, Toolchain{Name: rustVersion, Profile: profile, Range: rustVersionRange})
+		}
+
+		val, _ = cr.Resolve("BP_RUST_ADDITIONAL_TOOLCHAINS")
+		additionalToolchains, err := ParseAdditionalToolchains(val, profile)
+		if err != nil {
+			return libcnb.BuildResult{}, fmt.Errorf("unable to resolve additional toolchains\n%w", err)
+		}
+		for _, t := range additionalToolchains {
+			toolchains = AppendToolchain(toolchains, t)
+		}
+
+		defaultToolchain, err := DefaultToolchain(cr, toolchains)
+		if err != nil {
+			return libcnb.BuildResult{}, fmt.Errorf("unable to resolve default toolchain\n%w", err)
+		}
+
+		// $BP_RUST_TARGET and $BP_RUST_COMPONENTS apply to the default toolchain
+		for i, t := range toolchains {
+			if t.Name != defaultToolchain {
+				continue
+			}
+
+			if t.IsLinked() {
+				b.Logger.Bodyf("Skipping additional targets and components for linked toolchain %s", t.Name)
+			} else {
+				toolchains[i] = t.Merge(Toolchain{Targets: additionalTargets, Components: components})
+			}
+		}
+
+		// components, targets and profiles requested by other buildpacks are installed with the user configuration
+		toolchains = MergePlanToolchain(b.Logger, toolchains, planToolchain, defaultToolchain, profile)
+
+		if lockMode == LockModeRead {
+			if toolchains, err = lock.Pin(toolchains, true); err != nil {
+				return libcnb.BuildResult{}, fmt.Errorf("unable to pin toolchains\n%w", err)
+			}
+			if err = CheckToolchainFile(toolchainFile, toolchains); err != nil {
+				return libcnb.BuildResult{}, fmt.Errorf("unable to pin toolchains\n%w", err)
+			}
+		}
+
+		b.Logger.Header("Rust toolchains")
+		for _, t := range toolchains {
+			b.Logger.Body(t.String())
+		}
+		b.Logger.Bodyf("Default toolchain %s", defaultToolchain)
+
+		updatePolicy, err := ResolveUpdatePolicy(cr, b.Logger)
+		if err != nil {
+			return libcnb.BuildResult{}, fmt.Errorf("unable to resolve update policy\n%w", err)
+		}
+
+		advisoryDB, ok, err := ResolveAdvisoryDatabase(cr, context.Platform.Bindings, context.Application.Path)
+		if err != nil {
+			return libcnb.BuildResult{}, fmt.Errorf("unable to resolve advisory database\n%w", err)
+		}
+
+		var advisories []Advisory
+		if ok {
+			advisories, err = ReadAdvisories(advisoryDB)
+			if err != nil {
+				return libcnb.BuildResult{}, fmt.Errorf("unable to read advisory database\n%w", err)
+			}
+
+			b.Logger.Header("RustSec advisory database")
+			b.Logger.Bodyf("Read %d toolchain advisories from %s", len(advisories), advisoryDB)
+		}
+
+		requirements, err := ResolveRustVersionRequirements(cr, context.Application.Path)
+		if err != nil {
+			return libcnb.BuildResult{}, fmt.Errorf("unable to resolve minimum Rust versions\n%w", err)
+		}
+		if len(requirements) > 0 {
+			b.Logger.Header("Minimum Rust versions")
+			for _, r := range requirements {
+				b.Logger.Body(r.String())
+			}
+		}
+
+		rust := NewRust(toolchains, defaultToolchain)
 		rust.Logger = b.Logger
+		rust.UpdatePolicy = updatePolicy
+		rust.LockMode = lockMode
+		rust.LockPath = lockPath
+		rust.Lock = lock
+		rust.Mirror = mirror
+		rust.Advisories = advisories
+		rust.AdvisoryFail = cr.ResolveBool("BP_RUST_ADVISORY_FAIL")
+		rust.Requirements = requirements
+		rust.RequirementsFail = cr.ResolveBool("BP_RUST_VERSION_STRICT")
 
 		result.Layers = append(result.Layers, rust)
 	}
@@ -108,39 +377,122 @@ func (b Build) Build(context libcnb.BuildContext) (libcnb.BuildResult, error) {
 	return result, nil
 }
 
-func AdditionalTarget(cr libpak.ConfigurationResolver, stack string) string {
-	val, _ := cr.Resolve(("BP_RUST_TARGET"))
-	if val != "" {
-		return val
+// resolveToolchain resolves a toolchain of `msrv` to the rust-version of the application Cargo.toml and a version range
+// to the Rust release it matches. It returns the toolchain to install and the range it was resolved from, if any.
+func (b Build) resolveToolchain(name string, appPath string, ranges *RangeResolver) (string, string, error) {
+	if name == ToolchainMSRV {
+		var err error
+		if name, err = MSRVToolchain(appPath); err != nil {
+			return "", "", fmt.Errorf("unable to resolve minimum supported Rust version\n%w", err)
+		}
+
+		b.Logger.Header("Minimum supported Rust version")
+		b.Logger.Bodyf("Installing rust-version %s of Cargo.toml", name)
 	}
 
-	arch := "x86_64"
-	if runtime.GOARCH == "arm64" {
-		arch = "aarch64"
+	if !IsVersionRange(name) {
+		return name, "", nil
 	}
 
-	libc := "gnu"
-	if libpak.IsTinyStack(stack) || libpak.IsStaticStack(stack) {
-		libc = "musl"
+	version, err := ranges.Resolve(name)
+	if err != nil {
+		return "", "", err
 	}
 
-	return fmt.Sprintf("%s-unknown-linux-%s", arch, libc)
+	return version, name, nil
 }
 
-func rustToolChainFilePath(appPath string) (string, error) {
-	toolchainFilePath := filepath.Join(appPath, "rust-toolchain")
-	if _, err := os.Stat(toolchainFilePath); err == nil {
-		return toolchainFilePath, nil
-	} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
-		return "", err
+// DefaultToolchain returns the toolchain configured with $BP_RUST_DEFAULT_TOOLCHAIN, or the first toolchain if that is
+// not set. The default toolchain must be one of the installed toolchains.
+func DefaultToolchain(cr libpak.ConfigurationResolver, toolchains []Toolchain) (string, error) {
+	val, _ := cr.Resolve("BP_RUST_DEFAULT_TOOLCHAIN")
+	if val == "" {
+		if len(toolchains) == 0 {
+			return "", fmt.Errorf("no toolchains to install")
+		}
+		return toolchains[0].Name, nil
 	}
 
-	toolchainFilePath = filepath.Join(appPath, "rust-toolchain.toml")
-	if _, err := os.Stat(toolchainFilePath); err == nil {
-		return toolchainFilePath, nil
-	} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
-		return "", err
+	var names []string
+	for _, t := range toolchains {
+		if t.Name == val {
+			return val, nil
+		}
+		names = append(names, t.Name)
 	}
 
-	return "", nil
+	return "", fmt.Errorf("default toolchain %s is not one of the installed toolchains %s", val, strings.Join(names, ", "))
+}
+
+// AdditionalTargets returns the targets listed in $BP_RUST_TARGET. If none are listed, the host target of the platform
+// is returned. When the platform requires musl, like Alpine, the tiny and static stacks or a build image where rustup-init
+// uses musl, the musl host target is always added, as binaries must be statically linked. A host target that is needed
+// but has no Rust target triple is logged and skipped.
+func AdditionalTargets(logger bard.Logger, cr libpak.ConfigurationResolver, stack string, libc string) ([]string, error) {
+	val, _ := cr.Resolve("BP_RUST_TARGET")
+
+	targets, err := ParseTargets(val)
+	if err != nil {
+		return nil, err
+	}
+
+	platform := ResolveTargetPlatform(stack, libc)
+	if len(targets) > 0 && !platform.Musl {
+		return targets, nil
+	}
+
+	host, err := platform.HostTriple()
+	if err != nil {
+		logger.Bodyf("%s %s, no host target is added, set BP_RUST_TARGET to install targets",
+			color.YellowString("Warning:"), err)
+		return targets, nil
+	}
+
+	if !contains(targets, host) {
+		targets = append(targets, host)
+	}
+
+	return targets, nil
+}
+
+var targetPattern = regexp.MustCompile(`^[a-z0-9_]+(-[a-z0-9_.]+){1,3}$`)
+
+// ParseTargets splits a comma or space separated list of target triples, validating each of them
+func ParseTargets(val string) ([]string, error) {
+	var targets []string
+
+	for _, target := range splitList(val) {
+		if !targetPattern.MatchString(target) {
+			return nil, fmt.Errorf("invalid target %q, run `rustup target list` to see valid targets", target)
+		}
+		if !contains(targets, target) {
+			targets = append(targets, target)
+		}
+	}
+
+	return targets, nil
+}
+
+var componentPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]*$`)
+
+// ParseComponents splits a comma or space separated list of toolchain components, validating each of them
+func ParseComponents(val string) ([]string, error) {
+	var components []string
+
+	for _, component := range splitList(val) {
+		if !componentPattern.MatchString(component) {
+			return nil, fmt.Errorf("invalid component %q, run `rustup component list` to see valid components", component)
+		}
+		if !contains(components, component) {
+			components = append(components, component)
+		}
+	}
+
+	return components, nil
+}
+
+func splitList(val string) []string {
+	return strings.FieldsFunc(val, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
 }
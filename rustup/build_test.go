@@ -17,13 +17,16 @@
 package rustup_test
 
 import (
+	"bytes"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/buildpacks/libcnb"
 	. "github.com/onsi/gomega"
 	"github.com/paketo-buildpacks/libpak"
+	"github.com/paketo-buildpacks/libpak/bard"
 	"github.com/paketo-community/rustup/rustup"
 	"github.com/sclevine/spec"
 )
@@ -79,6 +82,452 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 			Expect(result.Layers[3].Name()).To(Equal("Rust"))
 		})
 
+		context("toolchains", func() {
+			it.After(func() {
+				Expect(os.Unsetenv("BP_RUST_TOOLCHAIN")).To(Succeed())
+				Expect(os.Unsetenv("BP_RUST_ADDITIONAL_TOOLCHAINS")).To(Succeed())
+				Expect(os.Unsetenv("BP_RUST_DEFAULT_TOOLCHAIN")).To(Succeed())
+				Expect(os.Unsetenv("BP_RUST_COMPONENTS")).To(Succeed())
+			})
+
+			it("installs the toolchain file and additional toolchains", func() {
+				Expect(os.WriteFile(filepath.Join(ctx.Application.Path, "rust-toolchain"), []byte("1.78.0"), 0644)).To(Succeed())
+				Expect(os.Setenv("BP_RUST_TOOLCHAIN", "stable")).To(Succeed())
+				Expect(os.Setenv("BP_RUST_ADDITIONAL_TOOLCHAINS", "nightly;components=miri")).To(Succeed())
+				Expect(os.Setenv("BP_RUST_DEFAULT_TOOLCHAIN", "stable")).To(Succeed())
+				Expect(os.Setenv("BP_RUST_COMPONENTS", "clippy")).To(Succeed())
+
+				result, err := build.Build(ctx)
+				Expect(err).NotTo(HaveOccurred())
+
+				rust := result.Layers[3].(rustup.Rust)
+				Expect(rust.DefaultToolchain).To(Equal("stable"))
+				Expect(rust.Toolchains).To(HaveLen(3))
+				Expect(rust.Toolchains[0].Name).To(Equal("1.78.0"))
+				Expect(rust.Toolchains[0].Components).To(BeEmpty())
+				Expect(rust.Toolchains[1].Name).To(Equal("stable"))
+				Expect(rust.Toolchains[1].Components).To(Equal([]string{"clippy"}))
+				Expect(rust.Toolchains[2].Name).To(Equal("nightly"))
+				Expect(rust.Toolchains[2].Components).To(Equal([]string{"miri"}))
+			})
+
+			it("installs the minimum supported Rust version", func() {
+				Expect(os.WriteFile(filepath.Join(ctx.Application.Path, "Cargo.toml"),
+					[]byte("[package]\nname = \"app\"\nrust-version = \"1.70\"\n"), 0644)).To(Succeed())
+				Expect(os.Setenv("BP_RUST_TOOLCHAIN", "msrv")).To(Succeed())
+
+				result, err := build.Build(ctx)
+				Expect(err).NotTo(HaveOccurred())
+
+				rust := result.Layers[3].(rustup.Rust)
+				Expect(rust.DefaultToolchain).To(Equal("1.70.0"))
+				Expect(rust.Toolchains).To(HaveLen(1))
+				Expect(rust.Toolchains[0].Name).To(Equal("1.70.0"))
+			})
+
+			it("fails to install the minimum supported Rust version without a rust-version", func() {
+				Expect(os.Setenv("BP_RUST_TOOLCHAIN", "msrv")).To(Succeed())
+
+				_, err := build.Build(ctx)
+				Expect(err).To(MatchError(ContainSubstring("which is required by BP_RUST_TOOLCHAIN=msrv")))
+			})
+
+			it("resolves a version range against the release index", func() {
+				Expect(os.WriteFile(filepath.Join(ctx.Application.Path, "manifests.txt"), []byte("1.77.2\n1.78.0\n1.79.0\n"), 0644)).To(Succeed())
+				Expect(os.Setenv("BP_RUST_TOOLCHAIN", ">=1.77, <1.79")).To(Succeed())
+				Expect(os.Setenv("BP_RUST_RELEASE_INDEX", "manifests.txt")).To(Succeed())
+				defer func() {
+					Expect(os.Unsetenv("BP_RUST_RELEASE_INDEX")).To(Succeed())
+				}()
+
+				result, err := build.Build(ctx)
+				Expect(err).NotTo(HaveOccurred())
+
+				rust := result.Layers[3].(rustup.Rust)
+				Expect(rust.DefaultToolchain).To(Equal("1.78.0"))
+				Expect(rust.Toolchains[0].Name).To(Equal("1.78.0"))
+				Expect(rust.Toolchains[0].Range).To(Equal(">=1.77, <1.79"))
+				Expect(rust.LayerContributor.ExpectedMetadata.(map[string]interface{})["toolchains"].([]map[string]interface{})[0]).
+					To(HaveKeyWithValue("range", ">=1.77, <1.79"))
+			})
+
+			it("installs the toolchain of the file with the highest precedence", func() {
+				Expect(os.WriteFile(filepath.Join(ctx.Application.Path, ".tool-versions"), []byte("rust 1.77.2\n"), 0644)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(ctx.Application.Path, "mise.toml"), []byte("[tools]\nrust = \"1.78.0\"\n"), 0644)).To(Succeed())
+
+				result, err := build.Build(ctx)
+				Expect(err).NotTo(HaveOccurred())
+
+				rust := result.Layers[3].(rustup.Rust)
+				Expect(rust.DefaultToolchain).To(Equal("1.78.0"))
+				Expect(rust.Toolchains).To(HaveLen(1))
+			})
+
+			it("installs the toolchain requested in the build plan", func() {
+				ctx.Plan.Entries = []libcnb.BuildpackPlanEntry{
+					{Name: "rust", Metadata: map[string]interface{}{
+						"toolchain":  "nightly",
+						"components": []interface{}{"rust-src"},
+						"targets":    []interface{}{"wasm32-unknown-unknown"},
+					}},
+				}
+				defer func() { ctx.Plan.Entries = nil }()
+
+				result, err := build.Build(ctx)
+				Expect(err).NotTo(HaveOccurred())
+
+				rust := result.Layers[3].(rustup.Rust)
+				Expect(rust.DefaultToolchain).To(Equal("nightly"))
+				Expect(rust.Toolchains).To(HaveLen(1))
+				Expect(rust.Toolchains[0].Components).To(Equal([]string{"rust-src"}))
+				Expect(rust.Toolchains[0].Targets).To(ContainElement("wasm32-unknown-unknown"))
+			})
+
+			it("installs the build plan toolchain next to the configured toolchain", func() {
+				Expect(os.Setenv("BP_RUST_TOOLCHAIN", "stable")).To(Succeed())
+				Expect(os.Setenv("BP_RUST_PROFILE", "minimal")).To(Succeed())
+				Expect(os.Setenv("BP_RUST_COMPONENTS", "clippy")).To(Succeed())
+				defer func() {
+					Expect(os.Unsetenv("BP_RUST_PROFILE")).To(Succeed())
+				}()
+				ctx.Plan.Entries = []libcnb.BuildpackPlanEntry{
+					{Name: "rust", Metadata: map[string]interface{}{"toolchain": "nightly", "components": "miri"}},
+					{Name: "rust", Metadata: map[string]interface{}{"components": "rust-src"}},
+				}
+				defer func() { ctx.Plan.Entries = nil }()
+
+				result, err := build.Build(ctx)
+				Expect(err).NotTo(HaveOccurred())
+
+				rust := result.Layers[3].(rustup.Rust)
+				Expect(rust.DefaultToolchain).To(Equal("stable"))
+				Expect(rust.Toolchains).To(HaveLen(2))
+				Expect(rust.Toolchains[0].Name).To(Equal("stable"))
+				Expect(rust.Toolchains[0].Components).To(Equal([]string{"clippy"}))
+				Expect(rust.Toolchains[1].Name).To(Equal("nightly"))
+				Expect(rust.Toolchains[1].Profile).To(Equal("minimal"))
+				Expect(rust.Toolchains[1].Components).To(Equal([]string{"miri", "rust-src"}))
+			})
+
+			it("resolves the build plan toolchain next to the configured toolchain", func() {
+				index, err := filepath.Abs(filepath.Join("testdata", "manifests.txt"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(os.Setenv("BP_RUST_RELEASE_INDEX", index)).To(Succeed())
+				Expect(os.Setenv("BP_RUST_TOOLCHAIN", "stable")).To(Succeed())
+				defer func() {
+					Expect(os.Unsetenv("BP_RUST_RELEASE_INDEX")).To(Succeed())
+					ctx.Plan.Entries = nil
+				}()
+				Expect(os.WriteFile(filepath.Join(ctx.Application.Path, "Cargo.toml"),
+					[]byte("[package]\nname = \"app\"\nrust-version = \"1.77\"\n"), 0644)).To(Succeed())
+
+				for requested, expected := range map[string]rustup.Toolchain{
+					"1.78.*": {Name: "1.78.0", Range: "1.78.*"},
+					"msrv":   {Name: "1.77.0"},
+				} {
+					ctx.Plan.Entries = []libcnb.BuildpackPlanEntry{
+						{Name: "rust", Metadata: map[string]interface{}{"toolchain": requested}},
+					}
+
+					result, err := build.Build(ctx)
+					Expect(err).NotTo(HaveOccurred())
+
+					rust := result.Layers[3].(rustup.Rust)
+					Expect(rust.DefaultToolchain).To(Equal("stable"))
+					Expect(rust.Toolchains).To(HaveLen(2))
+					Expect(rust.Toolchains[1].Name).To(Equal(expected.Name))
+					Expect(rust.Toolchains[1].Range).To(Equal(expected.Range))
+				}
+			})
+
+			it("merges build plan components into the default toolchain", func() {
+				Expect(os.Setenv("BP_RUST_TOOLCHAIN", "stable")).To(Succeed())
+				ctx.Plan.Entries = []libcnb.BuildpackPlanEntry{
+					{Name: "rust", Metadata: map[string]interface{}{"profile": "default", "components": "rust-src"}},
+				}
+				defer func() { ctx.Plan.Entries = nil }()
+
+				result, err := build.Build(ctx)
+				Expect(err).NotTo(HaveOccurred())
+
+				rust := result.Layers[3].(rustup.Rust)
+				Expect(rust.Toolchains).To(HaveLen(1))
+				Expect(rust.Toolchains[0].Name).To(Equal("stable"))
+				Expect(rust.Toolchains[0].Profile).To(Equal("default"))
+				Expect(rust.Toolchains[0].Components).To(Equal([]string{"rust-src"}))
+			})
+
+			it("rejects a default toolchain that is not installed", func() {
+				Expect(os.Setenv("BP_RUST_DEFAULT_TOOLCHAIN", "beta")).To(Succeed())
+
+				_, err := build.Build(ctx)
+				Expect(err).To(MatchError(ContainSubstring("default toolchain beta is not one of the installed toolchains")))
+			})
+		})
+
+		context("toolchain lock", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_RUST_TOOLCHAIN", "stable")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_RUST_TOOLCHAIN")).To(Succeed())
+				Expect(os.Unsetenv("BP_RUST_TOOLCHAIN_LOCK")).To(Succeed())
+			})
+
+			it("writes the lock file in auto mode without a lock file", func() {
+				Expect(os.Setenv("BP_RUST_TOOLCHAIN_LOCK", "auto")).To(Succeed())
+
+				result, err := build.Build(ctx)
+				Expect(err).NotTo(HaveOccurred())
+
+				rust := result.Layers[3].(rustup.Rust)
+				Expect(rust.LockMode).To(Equal(rustup.LockModeWrite))
+				Expect(rust.LockPath).To(Equal(filepath.Join(ctx.Application.Path, rustup.LockFileName)))
+				Expect(rust.Toolchains[0].Pinned).To(BeEmpty())
+			})
+
+			it("pins toolchains in auto mode with a lock file", func() {
+				Expect(os.Setenv("BP_RUST_TOOLCHAIN_LOCK", "auto")).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(ctx.Application.Path, rustup.LockFileName),
+					[]byte("[[toolchain]]\nname = \"stable\"\nchannel = \"1.78.0\"\nhash = \"abc\"\n"), 0644)).To(Succeed())
+
+				result, err := build.Build(ctx)
+				Expect(err).NotTo(HaveOccurred())
+
+				rust := result.Layers[3].(rustup.Rust)
+				Expect(rust.LockMode).To(Equal(rustup.LockModeRead))
+				Expect(rust.Toolchains[0].Pinned).To(Equal("1.78.0"))
+			})
+
+			it("pins a locked version range without reading the release index", func() {
+				Expect(os.Setenv("BP_RUST_TOOLCHAIN", "1.78.*")).To(Succeed())
+				Expect(os.Setenv("BP_RUST_TOOLCHAIN_LOCK", "read")).To(Succeed())
+				Expect(os.Setenv("BP_RUST_RELEASE_INDEX", "missing/manifests.txt")).To(Succeed())
+				defer func() {
+					Expect(os.Unsetenv("BP_RUST_RELEASE_INDEX")).To(Succeed())
+				}()
+				Expect(os.WriteFile(filepath.Join(ctx.Application.Path, rustup.LockFileName),
+					[]byte("[[toolchain]]\nname = \"1.78.*\"\nchannel = \"1.78.0\"\nhash = \"abc\"\n"), 0644)).To(Succeed())
+
+				result, err := build.Build(ctx)
+				Expect(err).NotTo(HaveOccurred())
+
+				rust := result.Layers[3].(rustup.Rust)
+				Expect(rust.Toolchains[0].Name).To(Equal("1.78.0"))
+				Expect(rust.Toolchains[0].Range).To(Equal("1.78.*"))
+				Expect(rust.Toolchains[0].Pinned).To(Equal("1.78.0"))
+			})
+
+			it("resolves a version range that is not locked", func() {
+				Expect(os.Setenv("BP_RUST_TOOLCHAIN", "1.78.*")).To(Succeed())
+				Expect(os.Setenv("BP_RUST_TOOLCHAIN_LOCK", "read")).To(Succeed())
+				Expect(os.Setenv("BP_RUST_RELEASE_INDEX", "missing/manifests.txt")).To(Succeed())
+				defer func() {
+					Expect(os.Unsetenv("BP_RUST_RELEASE_INDEX")).To(Succeed())
+				}()
+				Expect(os.WriteFile(filepath.Join(ctx.Application.Path, rustup.LockFileName),
+					[]byte("[[toolchain]]\nname = \"stable\"\nchannel = \"1.78.0\"\nhash = \"abc\"\n"), 0644)).To(Succeed())
+
+				_, err := build.Build(ctx)
+				Expect(err).To(MatchError(ContainSubstring("unable to read Rust release index")))
+			})
+
+			it("rejects a floating channel in rust-toolchain.toml pinned by the lock file", func() {
+				Expect(os.Unsetenv("BP_RUST_TOOLCHAIN")).To(Succeed())
+				Expect(os.Setenv("BP_RUST_TOOLCHAIN_LOCK", "read")).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(ctx.Application.Path, "rust-toolchain.toml"),
+					[]byte("[toolchain]\nchannel = \"stable\"\n"), 0644)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(ctx.Application.Path, rustup.LockFileName),
+					[]byte("[[toolchain]]\nname = \"stable\"\nchannel = \"1.78.0\"\nhash = \"abc\"\n"), 0644)).To(Succeed())
+
+				_, err := build.Build(ctx)
+				Expect(err).To(MatchError(ContainSubstring("rust-toolchain.toml requests stable, which rustup resolves again")))
+			})
+
+			it("pins an exact release in rust-toolchain.toml", func() {
+				Expect(os.Unsetenv("BP_RUST_TOOLCHAIN")).To(Succeed())
+				Expect(os.Setenv("BP_RUST_TOOLCHAIN_LOCK", "read")).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(ctx.Application.Path, "rust-toolchain.toml"),
+					[]byte("[toolchain]\nchannel = \"1.78.0\"\n"), 0644)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(ctx.Application.Path, rustup.LockFileName),
+					[]byte("[[toolchain]]\nname = \"1.78.0\"\nchannel = \"1.78.0\"\nhash = \"abc\"\n"), 0644)).To(Succeed())
+
+				result, err := build.Build(ctx)
+				Expect(err).NotTo(HaveOccurred())
+
+				rust := result.Layers[3].(rustup.Rust)
+				Expect(rust.Toolchains[0].Name).To(Equal("1.78.0"))
+				Expect(rust.Toolchains[0].Pinned).To(Equal("1.78.0"))
+			})
+
+			it("fails in read mode without a lock file", func() {
+				Expect(os.Setenv("BP_RUST_TOOLCHAIN_LOCK", "read")).To(Succeed())
+
+				_, err := build.Build(ctx)
+				Expect(err).To(MatchError(ContainSubstring("unable to find rust-toolchain.lock")))
+			})
+		})
+
+		it("installs packaged toolchains", func() {
+			ctx.Buildpack.Metadata["dependencies"] = append(ctx.Buildpack.Metadata["dependencies"].([]map[string]interface{}),
+				map[string]interface{}{
+					"id":      "rust-dist-manifest",
+					"version": "1.78.0",
+					"uri":     "https://static.rust-lang.org/dist/2024-05-02/channel-rust-1.78.0.toml",
+					"stacks":  []interface{}{"test-stack-id"},
+				},
+				map[string]interface{}{
+					"id":      "rust-dist-rustc",
+					"version": "1.78.0",
+					"uri":     "https://static.rust-lang.org/dist/2024-05-02/rustc-1.78.0-x86_64-unknown-linux-gnu.tar.xz",
+					"stacks":  []interface{}{"test-stack-id"},
+				},
+			)
+			ctx.Layers.Path = "/layers"
+
+			result, err := build.Build(ctx)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Layers).To(HaveLen(5))
+			Expect(result.Layers[2].Name()).To(Equal("rust-dist"))
+			Expect(result.Layers[2].(rustup.RustDist).Dependencies).To(HaveLen(2))
+			Expect(result.Layers[3].(rustup.Rustup).Mirror.DistServer).To(Equal("file:///layers/rust-dist"))
+			Expect(result.Layers[4].(rustup.Rust).Mirror.DistServer).To(Equal("file:///layers/rust-dist"))
+		})
+
+		it("resolves a version range against packaged toolchains", func() {
+			ctx.Buildpack.Metadata["dependencies"] = append(ctx.Buildpack.Metadata["dependencies"].([]map[string]interface{}),
+				map[string]interface{}{
+					"id":      "rust-dist-manifest",
+					"version": "1.78.0",
+					"uri":     "https://static.rust-lang.org/dist/2024-05-02/channel-rust-1.78.0.toml",
+					"stacks":  []interface{}{"test-stack-id"},
+				},
+				map[string]interface{}{
+					"id":      "rust-dist-manifest",
+					"version": "1.79.0",
+					"uri":     "https://static.rust-lang.org/dist/2024-06-13/channel-rust-1.79.0.toml",
+					"stacks":  []interface{}{"test-stack-id"},
+				},
+			)
+			ctx.Layers.Path = "/layers"
+			Expect(os.Setenv("BP_RUST_TOOLCHAIN", "1.78.*")).To(Succeed())
+			defer func() {
+				Expect(os.Unsetenv("BP_RUST_TOOLCHAIN")).To(Succeed())
+			}()
+
+			result, err := build.Build(ctx)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Layers[4].(rustup.Rust).Toolchains[0].Name).To(Equal("1.78.0"))
+		})
+
+		it("contributes cargo registry credentials", func() {
+			ctx.Platform.Bindings = libcnb.Bindings{
+				{Name: "internal", Type: "cargo", Secret: map[string]string{"index": "https://cargo.example.com/index", "token": "secret"}},
+			}
+			defer func() { ctx.Platform.Bindings = nil }()
+
+			result, err := build.Build(ctx)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Layers).To(HaveLen(5))
+			Expect(result.Layers[1].(rustup.Cargo).Registries).To(HaveLen(1))
+			Expect(result.Layers[2].Name()).To(Equal("cargo-credentials"))
+		})
+
+		it("contributes CA certificates first", func() {
+			ctx.Platform.Bindings = libcnb.Bindings{
+				{Name: "proxy", Type: "ca-certificates", Secret: map[string]string{"ca.pem": "-----BEGIN CERTIFICATE-----\n"}},
+			}
+			defer func() { ctx.Platform.Bindings = nil }()
+
+			result, err := build.Build(ctx)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Layers).To(HaveLen(5))
+			Expect(result.Layers[0].Name()).To(Equal("ca-certificates"))
+		})
+
+		context("advisory database", func() {
+			it.After(func() {
+				Expect(os.Unsetenv("BP_RUST_ADVISORY_DB")).To(Succeed())
+				Expect(os.Unsetenv("BP_RUST_ADVISORY_FAIL")).To(Succeed())
+			})
+
+			it("reads advisories from a directory", func() {
+				path, err := filepath.Abs(filepath.Join("testdata", "advisory-db"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(os.Setenv("BP_RUST_ADVISORY_DB", path)).To(Succeed())
+				Expect(os.Setenv("BP_RUST_ADVISORY_FAIL", "true")).To(Succeed())
+
+				result, err := build.Build(ctx)
+				Expect(err).NotTo(HaveOccurred())
+
+				rust := result.Layers[3].(rustup.Rust)
+				Expect(rust.Advisories).To(HaveLen(2))
+				Expect(rust.AdvisoryFail).To(BeTrue())
+			})
+
+			it("reads advisories from a binding", func() {
+				path, err := filepath.Abs(filepath.Join("testdata", "advisory-db"))
+				Expect(err).NotTo(HaveOccurred())
+				ctx.Platform.Bindings = libcnb.Bindings{{Name: "advisories", Type: "rustsec-advisory-db", Path: path}}
+				defer func() { ctx.Platform.Bindings = nil }()
+
+				result, err := build.Build(ctx)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(result.Layers[3].(rustup.Rust).Advisories).To(HaveLen(2))
+			})
+
+			it("fails when the directory does not exist", func() {
+				Expect(os.Setenv("BP_RUST_ADVISORY_DB", "advisory-db")).To(Succeed())
+
+				_, err := build.Build(ctx)
+				Expect(err).To(MatchError(ContainSubstring("unable to find advisory database")))
+			})
+		})
+
+		context("minimum Rust versions", func() {
+			it.After(func() {
+				Expect(os.Unsetenv("BP_RUST_MIN_VERSION")).To(Succeed())
+				Expect(os.Unsetenv("BP_RUST_VERSION_STRICT")).To(Succeed())
+			})
+
+			it("reads the rust-version and $BP_RUST_MIN_VERSION", func() {
+				Expect(os.WriteFile(filepath.Join(ctx.Application.Path, "Cargo.toml"),
+					[]byte("[package]\nname = \"app\"\nrust-version = \"1.70\"\n"), 0644)).To(Succeed())
+				Expect(os.Setenv("BP_RUST_MIN_VERSION", "1.75.0")).To(Succeed())
+				Expect(os.Setenv("BP_RUST_VERSION_STRICT", "true")).To(Succeed())
+
+				result, err := build.Build(ctx)
+				Expect(err).NotTo(HaveOccurred())
+
+				rust := result.Layers[3].(rustup.Rust)
+				Expect(rust.Requirements).To(Equal([]rustup.RustVersionRequirement{
+					{Source: "Cargo.toml rust-version", Version: "1.70"},
+					{Source: "$BP_RUST_MIN_VERSION", Version: "1.75.0"},
+				}))
+				Expect(rust.RequirementsFail).To(BeTrue())
+			})
+
+			it("rejects an invalid minimum version", func() {
+				Expect(os.Setenv("BP_RUST_MIN_VERSION", "latest")).To(Succeed())
+
+				_, err := build.Build(ctx)
+				Expect(err).To(MatchError(ContainSubstring("invalid $BP_RUST_MIN_VERSION \"latest\"")))
+			})
+		})
+
+		it("rejects an invalid rust-toolchain.toml", func() {
+			Expect(os.WriteFile(filepath.Join(ctx.Application.Path, "rust-toolchain.toml"), []byte("[toolchain]\nprofile = \"huge\"\n"), 0644)).To(Succeed())
+
+			_, err := build.Build(ctx)
+			Expect(err).To(MatchError(ContainSubstring("invalid profile")))
+		})
+
 		context("$BP_RUSTUP_ENABLED is set", func() {
 			context("to false", func() {
 				it.Before(func() {
@@ -180,7 +629,84 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 		})
 	})
 
-	context("pick additional target by stack", func() {
+	context("auto libc", func() {
+		it.Before(func() {
+			var err error
+
+			ctx.Application.Path, err = ioutil.TempDir("", "build")
+			Expect(err).NotTo(HaveOccurred())
+
+			ctx.Plan.Entries = append(ctx.Plan.Entries, libcnb.BuildpackPlanEntry{Name: "rust"})
+			ctx.Buildpack.Metadata = map[string]interface{}{
+				"dependencies": []map[string]interface{}{
+					{
+						"id":      "rustup-init-gnu",
+						"version": "1.24.3",
+						"stacks":  []interface{}{"test-stack-id"},
+					},
+					{
+						"id":      "rustup-init-musl",
+						"version": "1.24.3",
+						"stacks":  []interface{}{"test-stack-id"},
+					},
+				},
+				"configurations": []map[string]interface{}{
+					{
+						"name":        "BP_RUSTUP_ENABLED",
+						"description": "use rustup to install Rust",
+						"default":     "true",
+						"build":       true,
+					},
+					{
+						"name":        "BP_RUSTUP_INIT_LIBC",
+						"description": "libc implementation",
+						"default":     "auto",
+						"build":       true,
+					},
+				},
+			}
+			ctx.StackID = "test-stack-id"
+		})
+
+		it.After(func() {
+			Expect(os.RemoveAll(ctx.Application.Path)).To(Succeed())
+		})
+
+		it("contributes rustup-init and the host target for musl", func() {
+			build.LibcDetector = rustup.LibcDetector{Root: t.TempDir()}
+			Expect(os.MkdirAll(filepath.Join(build.LibcDetector.Root, "etc"), 0755)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(build.LibcDetector.Root, "etc", "os-release"),
+				[]byte("ID=custom\nID_LIKE=alpine\n"), 0644)).To(Succeed())
+
+			result, err := build.Build(ctx)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Layers).To(HaveLen(4))
+			Expect(result.Layers[0].Name()).To(Equal("rustup-init-musl"))
+
+			targets := result.Layers[3].(rustup.Rust).Toolchains[0].Targets
+			Expect(targets).To(HaveLen(1))
+			Expect(targets[0]).To(HaveSuffix("-unknown-linux-musl"))
+		})
+
+		it("contributes rustup-init and the host target for gnu", func() {
+			build.LibcDetector = rustup.LibcDetector{Root: t.TempDir()}
+			Expect(os.MkdirAll(filepath.Join(build.LibcDetector.Root, "lib64"), 0755)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(build.LibcDetector.Root, "lib64", "ld-linux-x86-64.so.2"), nil, 0644)).To(Succeed())
+
+			result, err := build.Build(ctx)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Layers).To(HaveLen(4))
+			Expect(result.Layers[0].Name()).To(Equal("rustup-init-gnu"))
+
+			targets := result.Layers[3].(rustup.Rust).Toolchains[0].Targets
+			Expect(targets).To(HaveLen(1))
+			Expect(targets[0]).To(HaveSuffix("-unknown-linux-gnu"))
+		})
+	})
+
+	context("pick additional targets by stack", func() {
 		it("picks gnu libc by default", func() {
 			ctx.Buildpack.Metadata = map[string]interface{}{
 				"configurations": []map[string]interface{}{},
@@ -190,29 +716,110 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 			cr, err := libpak.NewConfigurationResolver(ctx.Buildpack, nil)
 			Expect(err).ToNot(HaveOccurred())
 
-			target := rustup.AdditionalTarget(cr, libpak.BionicStackID)
-			Expect(target).To(HaveSuffix("-unknown-linux-gnu"))
+			targets, err := rustup.AdditionalTargets(bard.NewLogger(ioutil.Discard), cr, libpak.BionicStackID, "")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(targets).To(HaveLen(1))
+			Expect(targets[0]).To(HaveSuffix("-unknown-linux-gnu"))
 		})
 
 		context("user value is set", func() {
-			it("picks the user set value", func() {
-				ctx.Buildpack.Metadata = map[string]interface{}{
-					"configurations": []map[string]interface{}{
-						{
-							"name":        "BP_RUST_TARGET",
-							"description": "additional rust target",
-							"default":     "foo",
-							"build":       true,
-						},
-					},
-				}
-				ctx.StackID = "test-stack-id"
+			it.After(func() {
+				Expect(os.Unsetenv("BP_RUST_TARGET")).To(Succeed())
+			})
+
+			it("picks the user set values", func() {
+				Expect(os.Setenv("BP_RUST_TARGET", "wasm32-unknown-unknown, aarch64-unknown-linux-musl x86_64-unknown-linux-musl")).To(Succeed())
 
 				cr, err := libpak.NewConfigurationResolver(ctx.Buildpack, nil)
 				Expect(err).ToNot(HaveOccurred())
 
-				target := rustup.AdditionalTarget(cr, libpak.BionicStackID)
-				Expect(target).To(Equal("foo"))
+				targets, err := rustup.AdditionalTargets(bard.NewLogger(ioutil.Discard), cr, libpak.BionicStackID, "")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(targets).To(Equal([]string{"wasm32-unknown-unknown", "aarch64-unknown-linux-musl", "x86_64-unknown-linux-musl"}))
+			})
+
+			it("adds musl on top of the user set values for static stack", func() {
+				Expect(os.Setenv("BP_RUST_TARGET", "wasm32-unknown-unknown")).To(Succeed())
+
+				cr, err := libpak.NewConfigurationResolver(ctx.Buildpack, nil)
+				Expect(err).ToNot(HaveOccurred())
+
+				targets, err := rustup.AdditionalTargets(bard.NewLogger(ioutil.Discard), cr, libpak.JammyStaticStackID, "")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(targets).To(HaveLen(2))
+				Expect(targets[0]).To(Equal("wasm32-unknown-unknown"))
+				Expect(targets[1]).To(HaveSuffix("-unknown-linux-musl"))
+			})
+
+			it("rejects an invalid target", func() {
+				Expect(os.Setenv("BP_RUST_TARGET", "wasm32-unknown-unknown,foo")).To(Succeed())
+
+				cr, err := libpak.NewConfigurationResolver(ctx.Buildpack, nil)
+				Expect(err).ToNot(HaveOccurred())
+
+				_, err = rustup.AdditionalTargets(bard.NewLogger(ioutil.Discard), cr, libpak.BionicStackID, "")
+				Expect(err).To(MatchError(ContainSubstring(`invalid target "foo"`)))
+			})
+		})
+
+		context("platform target is set", func() {
+			it.After(func() {
+				Expect(os.Unsetenv("CNB_TARGET_ARCH")).To(Succeed())
+				Expect(os.Unsetenv("CNB_TARGET_DISTRO_NAME")).To(Succeed())
+			})
+
+			it("picks the platform architecture", func() {
+				Expect(os.Setenv("CNB_TARGET_ARCH", "ppc64le")).To(Succeed())
+				Expect(os.Setenv("CNB_TARGET_DISTRO_NAME", "ubuntu")).To(Succeed())
+
+				cr, err := libpak.NewConfigurationResolver(ctx.Buildpack, nil)
+				Expect(err).ToNot(HaveOccurred())
+
+				targets, err := rustup.AdditionalTargets(bard.NewLogger(ioutil.Discard), cr, "", "")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(targets).To(Equal([]string{"powerpc64le-unknown-linux-gnu"}))
+			})
+
+			it("picks musl for alpine", func() {
+				Expect(os.Setenv("CNB_TARGET_ARCH", "arm64")).To(Succeed())
+				Expect(os.Setenv("CNB_TARGET_DISTRO_NAME", "alpine")).To(Succeed())
+
+				cr, err := libpak.NewConfigurationResolver(ctx.Buildpack, nil)
+				Expect(err).ToNot(HaveOccurred())
+
+				targets, err := rustup.AdditionalTargets(bard.NewLogger(ioutil.Discard), cr, "", "")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(targets).To(Equal([]string{"aarch64-unknown-linux-musl"}))
+			})
+
+			it("skips the host target of an unsupported architecture", func() {
+				Expect(os.Setenv("CNB_TARGET_ARCH", "loong64")).To(Succeed())
+
+				cr, err := libpak.NewConfigurationResolver(ctx.Buildpack, nil)
+				Expect(err).ToNot(HaveOccurred())
+
+				buf := &bytes.Buffer{}
+				targets, err := rustup.AdditionalTargets(bard.NewLogger(buf), cr, "", "")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(targets).To(BeEmpty())
+				Expect(buf.String()).To(ContainSubstring("unsupported architecture loong64, no host target is added"))
+			})
+
+			it("does not need the host target of an unsupported architecture with BP_RUST_TARGET", func() {
+				Expect(os.Setenv("CNB_TARGET_ARCH", "386")).To(Succeed())
+				Expect(os.Setenv("BP_RUST_TARGET", "wasm32-unknown-unknown")).To(Succeed())
+				defer func() {
+					Expect(os.Unsetenv("BP_RUST_TARGET")).To(Succeed())
+				}()
+
+				cr, err := libpak.NewConfigurationResolver(ctx.Buildpack, nil)
+				Expect(err).ToNot(HaveOccurred())
+
+				buf := &bytes.Buffer{}
+				targets, err := rustup.AdditionalTargets(bard.NewLogger(buf), cr, "", "")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(targets).To(Equal([]string{"wasm32-unknown-unknown"}))
+				Expect(buf.String()).To(BeEmpty())
 			})
 		})
 
@@ -225,8 +832,10 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 			cr, err := libpak.NewConfigurationResolver(ctx.Buildpack, nil)
 			Expect(err).ToNot(HaveOccurred())
 
-			target := rustup.AdditionalTarget(cr, libpak.BionicTinyStackID)
-			Expect(target).To(HaveSuffix("-unknown-linux-musl"))
+			targets, err := rustup.AdditionalTargets(bard.NewLogger(ioutil.Discard), cr, libpak.BionicTinyStackID, "")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(targets).To(HaveLen(1))
+			Expect(targets[0]).To(HaveSuffix("-unknown-linux-musl"))
 		})
 
 		it("picks musl for static stack", func() {
@@ -238,8 +847,10 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 			cr, err := libpak.NewConfigurationResolver(ctx.Buildpack, nil)
 			Expect(err).ToNot(HaveOccurred())
 
-			target := rustup.AdditionalTarget(cr, libpak.JammyStaticStackID)
-			Expect(target).To(HaveSuffix("-unknown-linux-musl"))
+			targets, err := rustup.AdditionalTargets(bard.NewLogger(ioutil.Discard), cr, libpak.JammyStaticStackID, "")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(targets).To(HaveLen(1))
+			Expect(targets[0]).To(HaveSuffix("-unknown-linux-musl"))
 		})
 	})
 }
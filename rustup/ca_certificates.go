@@ -0,0 +1,177 @@
+/*
+ * Copyright 2018-2024 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rustup
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/buildpacks/libcnb"
+	"github.com/heroku/color"
+	"github.com/paketo-buildpacks/libpak/bard"
+	"github.com/paketo-buildpacks/libpak/bindings"
+)
+
+// SystemCABundles are the locations of the CA bundle on common distributions, the first one that exists is used
+var SystemCABundles = []string{
+	"/etc/ssl/certs/ca-certificates.crt",
+	"/etc/pki/tls/certs/ca-bundle.crt",
+	"/etc/ssl/ca-bundle.pem",
+	"/etc/ssl/cert.pem",
+}
+
+// CACertificate is a certificate provided with a binding of type `ca-certificates`
+type CACertificate struct {
+	// Name identifies the certificate as `<binding>/<key>`
+	Name string
+
+	// PEM is the PEM encoded certificate
+	PEM string
+}
+
+// ResolveCACertificates returns the certificates of each binding of type `ca-certificates`, every key of the binding is
+// a PEM encoded certificate
+func ResolveCACertificates(binds libcnb.Bindings) ([]CACertificate, error) {
+	var certificates []CACertificate
+
+	for _, b := range bindings.Resolve(binds, bindings.OfType("ca-certificates")) {
+		var keys []string
+		for k := range b.Secret {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			if !bytes.Contains([]byte(b.Secret[k]), []byte("-----BEGIN CERTIFICATE-----")) {
+				return nil, fmt.Errorf("binding %s key %s is not a PEM encoded certificate", b.Name, k)
+			}
+
+			certificates = append(certificates, CACertificate{Name: fmt.Sprintf("%s/%s", b.Name, k), PEM: b.Secret[k]})
+		}
+	}
+
+	return certificates, nil
+}
+
+// CertPool returns the system CAs and the certificates from bindings for HTTPS requests made by the buildpack itself.
+// The system bundle is read from $SSL_CERT_FILE or the first of the given bundles that exists, rather than with
+// x509.SystemCertPool, which is loaded only once per process and would not see the $SSL_CERT_FILE that CACertificates
+// sets for later downloads. It returns nil, which uses the system CAs, if there are no certificates from bindings.
+func CertPool(systemBundles []string, certificates []CACertificate) (*x509.CertPool, error) {
+	if len(certificates) == 0 {
+		return nil, nil
+	}
+
+	pool := x509.NewCertPool()
+
+	if path, ok := os.LookupEnv("SSL_CERT_FILE"); ok && path != "" {
+		systemBundles = append([]string{path}, systemBundles...)
+	}
+	for _, path := range systemBundles {
+		raw, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("unable to read %s\n%w", path, err)
+		}
+
+		pool.AppendCertsFromPEM(raw)
+		break
+	}
+
+	for _, cert := range certificates {
+		if !pool.AppendCertsFromPEM([]byte(cert.PEM)) {
+			return nil, fmt.Errorf("unable to parse CA certificate %s", cert.Name)
+		}
+	}
+
+	return pool, nil
+}
+
+// CACertificates writes a CA bundle containing the system CAs and the certificates from bindings, and points
+// $SSL_CERT_FILE and $CARGO_HTTP_CAINFO at it for rustup and cargo
+type CACertificates struct {
+	Logger        bard.Logger
+	Certificates  []CACertificate
+	SystemBundles []string
+}
+
+func NewCACertificates(certificates []CACertificate) CACertificates {
+	return CACertificates{
+		Certificates:  certificates,
+		SystemBundles: SystemCABundles,
+	}
+}
+
+func (c CACertificates) Contribute(layer libcnb.Layer) (libcnb.Layer, error) {
+	c.Logger.Headerf("%s: %s to layer", color.BlueString(c.Name()), color.YellowString("Contributing"))
+
+	buf := &bytes.Buffer{}
+
+	for _, path := range c.SystemBundles {
+		raw, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return libcnb.Layer{}, fmt.Errorf("unable to read %s\n%w", path, err)
+		}
+
+		c.Logger.Bodyf("Adding system CA certificates from %s", path)
+		buf.Write(raw)
+		break
+	}
+
+	for _, cert := range c.Certificates {
+		c.Logger.Bodyf("Adding CA certificate %s", cert.Name)
+		if buf.Len() > 0 && !bytes.HasSuffix(buf.Bytes(), []byte("\n")) {
+			buf.WriteString("\n")
+		}
+		buf.WriteString(cert.PEM)
+	}
+
+	if err := os.MkdirAll(layer.Path, 0755); err != nil {
+		return libcnb.Layer{}, fmt.Errorf("unable to create %s\n%w", layer.Path, err)
+	}
+
+	bundle := filepath.Join(layer.Path, "ca-bundle.crt")
+	if err := os.WriteFile(bundle, buf.Bytes(), 0644); err != nil {
+		return libcnb.Layer{}, fmt.Errorf("unable to write %s\n%w", bundle, err)
+	}
+
+	for _, name := range []string{"SSL_CERT_FILE", "CARGO_HTTP_CAINFO"} {
+		if err := os.Setenv(name, bundle); err != nil {
+			return libcnb.Layer{}, fmt.Errorf("unable to set $%s\n%w", name, err)
+		}
+		layer.BuildEnvironment.Override(name, bundle)
+	}
+
+	layer.LayerTypes = libcnb.LayerTypes{
+		Build: true,
+	}
+
+	return layer, nil
+}
+
+func (c CACertificates) Name() string {
+	return "ca-certificates"
+}
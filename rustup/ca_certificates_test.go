@@ -0,0 +1,98 @@
+/*
+ * Copyright 2018-2024 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rustup_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/buildpacks/libcnb"
+	. "github.com/onsi/gomega"
+	"github.com/paketo-community/rustup/rustup"
+	"github.com/sclevine/spec"
+)
+
+func testCACertificates(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		ctx libcnb.BuildContext
+
+		certificate = "-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n"
+	)
+
+	it.Before(func() {
+		var err error
+
+		ctx.Layers.Path, err = os.MkdirTemp("", "ca-certificates-layers")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	it.After(func() {
+		Expect(os.Unsetenv("SSL_CERT_FILE")).To(Succeed())
+		Expect(os.Unsetenv("CARGO_HTTP_CAINFO")).To(Succeed())
+		Expect(os.RemoveAll(ctx.Layers.Path)).To(Succeed())
+	})
+
+	context("ResolveCACertificates", func() {
+		it("resolves certificates from bindings", func() {
+			certificates, err := rustup.ResolveCACertificates(libcnb.Bindings{
+				{Name: "proxy", Type: "ca-certificates", Secret: map[string]string{"b.pem": certificate, "a.pem": certificate}},
+				{Name: "other", Type: "maven", Secret: map[string]string{"settings.xml": ""}},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(certificates).To(Equal([]rustup.CACertificate{
+				{Name: "proxy/a.pem", PEM: certificate},
+				{Name: "proxy/b.pem", PEM: certificate},
+			}))
+		})
+
+		it("rejects a key that is not a certificate", func() {
+			_, err := rustup.ResolveCACertificates(libcnb.Bindings{
+				{Name: "proxy", Type: "ca-certificates", Secret: map[string]string{"type": "ca-certificates"}},
+			})
+			Expect(err).To(MatchError("binding proxy key type is not a PEM encoded certificate"))
+		})
+	})
+
+	it("contributes a CA bundle", func() {
+		system := filepath.Join(ctx.Layers.Path, "system.crt")
+		Expect(os.WriteFile(system, []byte("system"), 0644)).To(Succeed())
+
+		c := rustup.NewCACertificates([]rustup.CACertificate{{Name: "proxy/ca.pem", PEM: certificate}})
+		c.SystemBundles = []string{filepath.Join(ctx.Layers.Path, "missing.crt"), system}
+
+		layer, err := ctx.Layers.Layer(c.Name())
+		Expect(err).NotTo(HaveOccurred())
+
+		layer, err = c.Contribute(layer)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(layer.LayerTypes.Build).To(BeTrue())
+		Expect(layer.LayerTypes.Cache).To(BeFalse())
+		Expect(layer.LayerTypes.Launch).To(BeFalse())
+
+		bundle := filepath.Join(layer.Path, "ca-bundle.crt")
+		Expect(os.ReadFile(bundle)).To(Equal([]byte("system\n" + certificate)))
+
+		Expect(os.Getenv("SSL_CERT_FILE")).To(Equal(bundle))
+		Expect(os.Getenv("CARGO_HTTP_CAINFO")).To(Equal(bundle))
+		Expect(layer.BuildEnvironment).To(HaveKeyWithValue("SSL_CERT_FILE.override", bundle))
+		Expect(layer.BuildEnvironment).To(HaveKeyWithValue("CARGO_HTTP_CAINFO.override", bundle))
+	})
+}
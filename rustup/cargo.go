@@ -17,7 +17,10 @@
 package rustup
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 
@@ -28,7 +31,9 @@ import (
 )
 
 type Cargo struct {
-	Logger bard.Logger
+	Logger     bard.Logger
+	Registries []CargoRegistry
+	Sources    map[string]CargoSource
 }
 
 func (c Cargo) Contribute(layer libcnb.Layer) (libcnb.Layer, error) {
@@ -48,9 +53,59 @@ func (c Cargo) Contribute(layer libcnb.Layer) (libcnb.Layer, error) {
 		Cache: true,
 	}
 
+	// credentials are linked from a layer that is not cached, remove the link restored from a previous build
+	credentials := filepath.Join(layer.Path, "credentials.toml")
+	if fi, err := os.Lstat(credentials); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+		if err := os.Remove(credentials); err != nil {
+			return libcnb.Layer{}, fmt.Errorf("unable to remove %s\n%w", credentials, err)
+		}
+	}
+
+	config, err := CargoConfigToml(c.Registries, c.Sources)
+	if err != nil {
+		return libcnb.Layer{}, err
+	}
+
+	if err := writeGeneratedFile(filepath.Join(layer.Path, "config.toml"), config); err != nil {
+		return libcnb.Layer{}, err
+	}
+
+	for _, r := range c.Registries {
+		c.Logger.Bodyf("Configuring registry %s", r)
+	}
+
 	return layer, nil
 }
 
 func (c Cargo) Name() string {
 	return "Cargo"
 }
+
+// writeGeneratedFile writes a file generated by this buildpack. If there is no content, a file generated by a previous
+// build is removed, but a file written by someone else is kept.
+func writeGeneratedFile(path string, content []byte) error {
+	if len(content) > 0 {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("unable to create %s\n%w", filepath.Dir(path), err)
+		}
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			return fmt.Errorf("unable to write %s\n%w", path, err)
+		}
+		return nil
+	}
+
+	existing, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("unable to read %s\n%w", path, err)
+	}
+
+	if bytes.HasPrefix(existing, []byte("# generated by the Paketo Buildpack for Rustup")) {
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("unable to remove %s\n%w", path, err)
+		}
+	}
+
+	return nil
+}
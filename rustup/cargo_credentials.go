@@ -0,0 +1,74 @@
+/*
+ * Copyright 2018-2024 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rustup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/buildpacks/libcnb"
+	"github.com/heroku/color"
+	"github.com/paketo-buildpacks/libpak/bard"
+)
+
+// CargoCredentials writes the registry tokens to `credentials.toml` in a layer that is neither cached nor exported, and
+// links it from `$CARGO_HOME`
+type CargoCredentials struct {
+	Logger     bard.Logger
+	Registries []CargoRegistry
+}
+
+func (c CargoCredentials) Contribute(layer libcnb.Layer) (libcnb.Layer, error) {
+	c.Logger.Headerf("%s: %s to layer", color.BlueString(c.Name()), color.YellowString("Contributing"))
+
+	content, err := CargoCredentialsToml(c.Registries)
+	if err != nil {
+		return libcnb.Layer{}, err
+	}
+
+	if err := os.MkdirAll(layer.Path, 0755); err != nil {
+		return libcnb.Layer{}, fmt.Errorf("unable to create %s\n%w", layer.Path, err)
+	}
+
+	file := filepath.Join(layer.Path, "credentials.toml")
+	if err := os.WriteFile(file, content, 0600); err != nil {
+		return libcnb.Layer{}, fmt.Errorf("unable to write %s\n%w", file, err)
+	}
+
+	if cargoHome, ok := os.LookupEnv("CARGO_HOME"); ok {
+		link := filepath.Join(cargoHome, "credentials.toml")
+		if err := os.Remove(link); err != nil && !os.IsNotExist(err) {
+			return libcnb.Layer{}, fmt.Errorf("unable to remove %s\n%w", link, err)
+		}
+
+		c.Logger.Bodyf("Linking %s", link)
+		if err := os.Symlink(file, link); err != nil {
+			return libcnb.Layer{}, fmt.Errorf("unable to link %s to %s\n%w", link, file, err)
+		}
+	}
+
+	layer.LayerTypes = libcnb.LayerTypes{
+		Build: true,
+	}
+
+	return layer, nil
+}
+
+func (c CargoCredentials) Name() string {
+	return "cargo-credentials"
+}
@@ -0,0 +1,79 @@
+/*
+ * Copyright 2018-2024 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rustup_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/buildpacks/libcnb"
+	. "github.com/onsi/gomega"
+	"github.com/paketo-community/rustup/rustup"
+	"github.com/sclevine/spec"
+)
+
+func testCargoCredentials(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		ctx       libcnb.BuildContext
+		cargoHome string
+	)
+
+	it.Before(func() {
+		var err error
+
+		ctx.Layers.Path, err = os.MkdirTemp("", "cargo-credentials-layers")
+		Expect(err).NotTo(HaveOccurred())
+
+		cargoHome, err = os.MkdirTemp("", "cargo-home")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Setenv("CARGO_HOME", cargoHome)).To(Succeed())
+	})
+
+	it.After(func() {
+		Expect(os.Unsetenv("CARGO_HOME")).To(Succeed())
+		Expect(os.RemoveAll(ctx.Layers.Path)).To(Succeed())
+		Expect(os.RemoveAll(cargoHome)).To(Succeed())
+	})
+
+	it("contributes credentials to a layer that is not cached", func() {
+		c := rustup.CargoCredentials{Registries: []rustup.CargoRegistry{
+			{Name: "internal", Index: "https://cargo.example.com/index", Token: "secret"},
+		}}
+
+		layer, err := ctx.Layers.Layer(c.Name())
+		Expect(err).NotTo(HaveOccurred())
+
+		layer, err = c.Contribute(layer)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(layer.LayerTypes.Build).To(BeTrue())
+		Expect(layer.LayerTypes.Cache).To(BeFalse())
+		Expect(layer.LayerTypes.Launch).To(BeFalse())
+		Expect(layer.Metadata).To(BeEmpty())
+
+		stat, err := os.Stat(filepath.Join(layer.Path, "credentials.toml"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stat.Mode().Perm().String()).To(Equal("-rw-------"))
+
+		link, err := os.Readlink(filepath.Join(cargoHome, "credentials.toml"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(link).To(Equal(filepath.Join(layer.Path, "credentials.toml")))
+	})
+}
@@ -0,0 +1,162 @@
+/*
+ * Copyright 2018-2024 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rustup
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/buildpacks/libcnb"
+	"github.com/paketo-buildpacks/libpak/bindings"
+)
+
+// CratesIORegistry is the name of the default registry, which only accepts a token
+const CratesIORegistry = "crates-io"
+
+// CargoRegistry is an alternative Cargo registry configured with a binding
+type CargoRegistry struct {
+	// Name is the name the registry is referred to by in `Cargo.toml`
+	Name string
+
+	// Index is the URL of the registry index, like `sparse+https://cargo.example.com/index/`
+	Index string
+
+	// Token is the token to authenticate with, it must not be written to cached layers or metadata
+	Token string
+}
+
+var registryNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// ResolveCargoRegistries returns a registry for each binding of type `cargo` or `cargo-registry`. The registry is named
+// with the `name` key, or the binding name if the key is not set. The `index` key is required for registries other
+// than crates-io and the `token` key is optional.
+func ResolveCargoRegistries(binds libcnb.Bindings) ([]CargoRegistry, error) {
+	var resolved libcnb.Bindings
+	resolved = append(resolved, bindings.Resolve(binds, bindings.OfType("cargo"))...)
+	resolved = append(resolved, bindings.Resolve(binds, bindings.OfType("cargo-registry"))...)
+
+	var registries []CargoRegistry
+	for _, b := range resolved {
+		r := CargoRegistry{
+			Name:  b.Secret["name"],
+			Index: b.Secret["index"],
+			Token: b.Secret["token"],
+		}
+		if r.Name == "" {
+			r.Name = b.Name
+		}
+
+		if !registryNamePattern.MatchString(r.Name) {
+			return nil, fmt.Errorf("binding %s has an invalid registry name %q", b.Name, r.Name)
+		}
+		if r.Index == "" && r.Name != CratesIORegistry {
+			return nil, fmt.Errorf("binding %s is missing the required key index", b.Name)
+		}
+
+		for _, existing := range registries {
+			if existing.Name == r.Name {
+				return nil, fmt.Errorf("binding %s configures registry %s more than once", b.Name, r.Name)
+			}
+		}
+
+		registries = append(registries, r)
+	}
+
+	sort.Slice(registries, func(i, j int) bool {
+		return registries[i].Name < registries[j].Name
+	})
+
+	return registries, nil
+}
+
+func (r CargoRegistry) String() string {
+	s := []string{r.Name}
+	if r.Index != "" {
+		s = append(s, fmt.Sprintf("index=%s", redact(r.Index)))
+	}
+	if r.Token != "" {
+		s = append(s, "token=<redacted>")
+	}
+	return strings.Join(s, " ")
+}
+
+// CargoConfigToml returns the content of `$CARGO_HOME/config.toml` declaring the registries and sources. It does not
+// contain tokens.
+func CargoConfigToml(registries []CargoRegistry, sources map[string]CargoSource) ([]byte, error) {
+	content := map[string]interface{}{}
+
+	indexes := map[string]interface{}{}
+	for _, r := range registries {
+		if r.Index != "" {
+			indexes[r.Name] = map[string]interface{}{"index": r.Index}
+		}
+	}
+	if len(indexes) > 0 {
+		content["registries"] = indexes
+	}
+
+	if len(sources) > 0 {
+		content["source"] = sources
+	}
+
+	if len(content) == 0 {
+		return nil, nil
+	}
+
+	return encodeCargoToml(content)
+}
+
+// CargoCredentialsToml returns the content of `$CARGO_HOME/credentials.toml` with the tokens of the registries
+func CargoCredentialsToml(registries []CargoRegistry) ([]byte, error) {
+	content := map[string]interface{}{}
+	tokens := map[string]interface{}{}
+	for _, r := range registries {
+		if r.Token == "" {
+			continue
+		}
+
+		if r.Name == CratesIORegistry {
+			content["registry"] = map[string]interface{}{"token": r.Token}
+		} else {
+			tokens[r.Name] = map[string]interface{}{"token": r.Token}
+		}
+	}
+	if len(tokens) > 0 {
+		content["registries"] = tokens
+	}
+
+	if len(content) == 0 {
+		return nil, nil
+	}
+
+	return encodeCargoToml(content)
+}
+
+func encodeCargoToml(content map[string]interface{}) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	buf.WriteString("# generated by the Paketo Buildpack for Rustup\n")
+	encoder := toml.NewEncoder(buf)
+	encoder.Indent = ""
+	if err := encoder.Encode(content); err != nil {
+		return nil, fmt.Errorf("unable to encode cargo configuration\n%w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,116 @@
+/*
+ * Copyright 2018-2024 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rustup_test
+
+import (
+	"testing"
+
+	"github.com/buildpacks/libcnb"
+	. "github.com/onsi/gomega"
+	"github.com/paketo-community/rustup/rustup"
+	"github.com/sclevine/spec"
+)
+
+func testCargoRegistry(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+	)
+
+	context("ResolveCargoRegistries", func() {
+		it("resolves registries from bindings", func() {
+			registries, err := rustup.ResolveCargoRegistries(libcnb.Bindings{
+				{
+					Name:   "internal",
+					Type:   "cargo",
+					Secret: map[string]string{"index": "sparse+https://cargo.example.com/index/", "token": "secret"},
+				},
+				{
+					Name:   "other-binding",
+					Type:   "cargo-registry",
+					Secret: map[string]string{"name": "mirror", "index": "https://git.example.com/index.git"},
+				},
+				{
+					Name:   "unrelated",
+					Type:   "maven",
+					Secret: map[string]string{"settings.xml": ""},
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(registries).To(Equal([]rustup.CargoRegistry{
+				{Name: "internal", Index: "sparse+https://cargo.example.com/index/", Token: "secret"},
+				{Name: "mirror", Index: "https://git.example.com/index.git"},
+			}))
+			Expect(registries[0].String()).NotTo(ContainSubstring("secret"))
+		})
+
+		it("allows a token for crates-io without an index", func() {
+			registries, err := rustup.ResolveCargoRegistries(libcnb.Bindings{
+				{Name: "crates-io", Type: "cargo", Secret: map[string]string{"token": "secret"}},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(registries).To(Equal([]rustup.CargoRegistry{{Name: "crates-io", Token: "secret"}}))
+		})
+
+		it("rejects a registry without an index", func() {
+			_, err := rustup.ResolveCargoRegistries(libcnb.Bindings{
+				{Name: "internal", Type: "cargo", Secret: map[string]string{"token": "secret"}},
+			})
+			Expect(err).To(MatchError("binding internal is missing the required key index"))
+		})
+
+		it("rejects an invalid name", func() {
+			_, err := rustup.ResolveCargoRegistries(libcnb.Bindings{
+				{Name: "internal", Type: "cargo", Secret: map[string]string{"name": "my registry", "index": "https://example.com"}},
+			})
+			Expect(err).To(MatchError(ContainSubstring("invalid registry name \"my registry\"")))
+		})
+
+		it("rejects a registry configured twice", func() {
+			_, err := rustup.ResolveCargoRegistries(libcnb.Bindings{
+				{Name: "a", Type: "cargo", Secret: map[string]string{"name": "internal", "index": "https://example.com"}},
+				{Name: "b", Type: "cargo-registry", Secret: map[string]string{"name": "internal", "index": "https://example.com"}},
+			})
+			Expect(err).To(MatchError(ContainSubstring("configures registry internal more than once")))
+		})
+	})
+
+	context("configuration files", func() {
+		registries := []rustup.CargoRegistry{
+			{Name: "crates-io", Token: "crates-token"},
+			{Name: "internal", Index: "sparse+https://cargo.example.com/index/", Token: "internal-token"},
+		}
+
+		it("declares registries without tokens", func() {
+			config, err := rustup.CargoConfigToml(registries, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(config)).To(ContainSubstring("[registries.internal]\nindex = \"sparse+https://cargo.example.com/index/\""))
+			Expect(string(config)).NotTo(ContainSubstring("token"))
+		})
+
+		it("writes the tokens to credentials", func() {
+			credentials, err := rustup.CargoCredentialsToml(registries)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(credentials)).To(ContainSubstring("[registry]\ntoken = \"crates-token\""))
+			Expect(string(credentials)).To(ContainSubstring("[registries.internal]\ntoken = \"internal-token\""))
+		})
+
+		it("returns nothing without registries", func() {
+			Expect(rustup.CargoConfigToml(nil, nil)).To(BeEmpty())
+			Expect(rustup.CargoCredentialsToml(nil)).To(BeEmpty())
+		})
+	})
+}
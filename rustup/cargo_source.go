@@ -0,0 +1,164 @@
+/*
+ * Copyright 2018-2024 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rustup
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/paketo-buildpacks/libpak"
+)
+
+const (
+	// CratesIOSource is the name of the source Cargo downloads crates from by default
+	CratesIOSource = "crates-io"
+
+	// MirrorSource is the name of the source configured with $BP_CARGO_REGISTRY_MIRROR
+	MirrorSource = "mirror"
+
+	// VendoredSource is the name of the source configured with $BP_CARGO_VENDOR_DIR
+	VendoredSource = "vendored-sources"
+)
+
+// CargoSource is an entry in the `[source]` section of the Cargo configuration
+type CargoSource struct {
+	ReplaceWith string `toml:"replace-with,omitempty"`
+	Registry    string `toml:"registry,omitempty"`
+	Directory   string `toml:"directory,omitempty"`
+}
+
+func (s CargoSource) String() string {
+	var p []string
+	if s.ReplaceWith != "" {
+		p = append(p, fmt.Sprintf("replace-with=%s", s.ReplaceWith))
+	}
+	if s.Registry != "" {
+		p = append(p, fmt.Sprintf("registry=%s", redact(s.Registry)))
+	}
+	if s.Directory != "" {
+		p = append(p, fmt.Sprintf("directory=%s", s.Directory))
+	}
+	return strings.Join(p, " ")
+}
+
+// ResolveCargoSources returns the source replacement configured with $BP_CARGO_REGISTRY_MIRROR or
+// $BP_CARGO_VENDOR_DIR. A relative vendor directory is resolved against the application.
+func ResolveCargoSources(cr libpak.ConfigurationResolver, appPath string) (map[string]CargoSource, error) {
+	mirror, _ := cr.Resolve("BP_CARGO_REGISTRY_MIRROR")
+	vendorDir, _ := cr.Resolve("BP_CARGO_VENDOR_DIR")
+
+	mirror = strings.TrimSpace(mirror)
+	vendorDir = strings.TrimSpace(vendorDir)
+
+	switch {
+	case mirror != "" && vendorDir != "":
+		return nil, fmt.Errorf("BP_CARGO_REGISTRY_MIRROR and BP_CARGO_VENDOR_DIR must not both be set")
+
+	case mirror != "":
+		if !strings.Contains(mirror, "://") {
+			return nil, fmt.Errorf("invalid registry mirror %q, must be an index URL like sparse+https://example.com/index/", redact(mirror))
+		}
+
+		return map[string]CargoSource{
+			CratesIOSource: {ReplaceWith: MirrorSource},
+			MirrorSource:   {Registry: mirror},
+		}, nil
+
+	case vendorDir != "":
+		if !filepath.IsAbs(vendorDir) {
+			vendorDir = filepath.Join(appPath, vendorDir)
+		}
+
+		if fi, err := os.Stat(vendorDir); err != nil || !fi.IsDir() {
+			return nil, fmt.Errorf("vendor directory %s does not exist, run `cargo vendor` to create it", vendorDir)
+		}
+
+		return map[string]CargoSource{
+			CratesIOSource: {ReplaceWith: VendoredSource},
+			VendoredSource: {Directory: vendorDir},
+		}, nil
+	}
+
+	return nil, nil
+}
+
+// ReadAppCargoSources returns the `[source]` section of the application's `.cargo/config.toml`, or of the legacy
+// `.cargo/config`
+func ReadAppCargoSources(appPath string) (string, map[string]CargoSource, error) {
+	for _, name := range []string{"config.toml", "config"} {
+		path := filepath.Join(appPath, ".cargo", name)
+
+		raw, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return "", nil, fmt.Errorf("unable to read %s\n%w", path, err)
+		}
+
+		var c struct {
+			Source map[string]CargoSource `toml:"source"`
+		}
+		if _, err := toml.Decode(string(raw), &c); err != nil {
+			return "", nil, fmt.Errorf("unable to parse %s\n%w", path, err)
+		}
+
+		return path, c.Source, nil
+	}
+
+	return "", nil, nil
+}
+
+// EffectiveCargoSources merges sources the way Cargo does. The application's configuration takes precedence over
+// `$CARGO_HOME/config.toml`, key by key.
+func EffectiveCargoSources(generated map[string]CargoSource, app map[string]CargoSource) map[string]CargoSource {
+	effective := map[string]CargoSource{}
+	for name, s := range generated {
+		effective[name] = s
+	}
+
+	for name, s := range app {
+		e := effective[name]
+		if s.ReplaceWith != "" {
+			e.ReplaceWith = s.ReplaceWith
+		}
+		if s.Registry != "" {
+			e.Registry = s.Registry
+		}
+		if s.Directory != "" {
+			e.Directory = s.Directory
+		}
+		effective[name] = e
+	}
+
+	return effective
+}
+
+// SortedSourceNames returns the names of the sources in a stable order
+func SortedSourceNames(sources map[string]CargoSource) []string {
+	var names []string
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,149 @@
+/*
+ * Copyright 2018-2024 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rustup_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/buildpacks/libcnb"
+	. "github.com/onsi/gomega"
+	"github.com/paketo-buildpacks/libpak"
+	"github.com/paketo-community/rustup/rustup"
+	"github.com/sclevine/spec"
+)
+
+func testCargoSource(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		appPath string
+	)
+
+	it.Before(func() {
+		var err error
+
+		appPath, err = os.MkdirTemp("", "cargo-source")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	it.After(func() {
+		Expect(os.Unsetenv("BP_CARGO_REGISTRY_MIRROR")).To(Succeed())
+		Expect(os.Unsetenv("BP_CARGO_VENDOR_DIR")).To(Succeed())
+		Expect(os.RemoveAll(appPath)).To(Succeed())
+	})
+
+	resolver := func() libpak.ConfigurationResolver {
+		cr, err := libpak.NewConfigurationResolver(libcnb.Buildpack{}, nil)
+		Expect(err).NotTo(HaveOccurred())
+		return cr
+	}
+
+	context("ResolveCargoSources", func() {
+		it("returns nothing by default", func() {
+			sources, err := rustup.ResolveCargoSources(resolver(), appPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sources).To(BeEmpty())
+		})
+
+		it("replaces crates-io with a mirror", func() {
+			Expect(os.Setenv("BP_CARGO_REGISTRY_MIRROR", "sparse+https://artifactory.example.com/api/cargo/crates/index/")).To(Succeed())
+
+			sources, err := rustup.ResolveCargoSources(resolver(), appPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sources).To(Equal(map[string]rustup.CargoSource{
+				"crates-io": {ReplaceWith: "mirror"},
+				"mirror":    {Registry: "sparse+https://artifactory.example.com/api/cargo/crates/index/"},
+			}))
+		})
+
+		it("replaces crates-io with a vendor directory", func() {
+			Expect(os.MkdirAll(filepath.Join(appPath, "vendor"), 0755)).To(Succeed())
+			Expect(os.Setenv("BP_CARGO_VENDOR_DIR", "vendor")).To(Succeed())
+
+			sources, err := rustup.ResolveCargoSources(resolver(), appPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sources).To(Equal(map[string]rustup.CargoSource{
+				"crates-io":        {ReplaceWith: "vendored-sources"},
+				"vendored-sources": {Directory: filepath.Join(appPath, "vendor")},
+			}))
+		})
+
+		it("rejects a missing vendor directory", func() {
+			Expect(os.Setenv("BP_CARGO_VENDOR_DIR", "vendor")).To(Succeed())
+
+			_, err := rustup.ResolveCargoSources(resolver(), appPath)
+			Expect(err).To(MatchError(ContainSubstring("run `cargo vendor` to create it")))
+		})
+
+		it("rejects a mirror and a vendor directory", func() {
+			Expect(os.Setenv("BP_CARGO_REGISTRY_MIRROR", "sparse+https://example.com/index/")).To(Succeed())
+			Expect(os.Setenv("BP_CARGO_VENDOR_DIR", "vendor")).To(Succeed())
+
+			_, err := rustup.ResolveCargoSources(resolver(), appPath)
+			Expect(err).To(MatchError(ContainSubstring("must not both be set")))
+		})
+	})
+
+	context("application configuration", func() {
+		it("returns nothing without a configuration", func() {
+			path, sources, err := rustup.ReadAppCargoSources(appPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(path).To(BeEmpty())
+			Expect(sources).To(BeEmpty())
+		})
+
+		it("merges the application sources over the generated sources", func() {
+			Expect(os.MkdirAll(filepath.Join(appPath, ".cargo"), 0755)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(appPath, ".cargo", "config.toml"), []byte(`[build]
+target = "wasm32-unknown-unknown"
+
+[source.crates-io]
+replace-with = "internal"
+
+[source.internal]
+registry = "sparse+https://internal.example.com/index/"
+`), 0644)).To(Succeed())
+
+			path, app, err := rustup.ReadAppCargoSources(appPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(path).To(Equal(filepath.Join(appPath, ".cargo", "config.toml")))
+
+			effective := rustup.EffectiveCargoSources(map[string]rustup.CargoSource{
+				"crates-io": {ReplaceWith: "mirror"},
+				"mirror":    {Registry: "sparse+https://mirror.example.com/index/"},
+			}, app)
+			Expect(effective).To(Equal(map[string]rustup.CargoSource{
+				"crates-io": {ReplaceWith: "internal"},
+				"internal":  {Registry: "sparse+https://internal.example.com/index/"},
+				"mirror":    {Registry: "sparse+https://mirror.example.com/index/"},
+			}))
+			Expect(rustup.SortedSourceNames(effective)).To(Equal([]string{"crates-io", "internal", "mirror"}))
+		})
+	})
+
+	it("writes sources to the cargo configuration", func() {
+		config, err := rustup.CargoConfigToml(nil, map[string]rustup.CargoSource{
+			"crates-io": {ReplaceWith: "mirror"},
+			"mirror":    {Registry: "sparse+https://mirror.example.com/index/"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(config)).To(ContainSubstring("[source.crates-io]\nreplace-with = \"mirror\""))
+		Expect(string(config)).To(ContainSubstring("[source.mirror]\nregistry = \"sparse+https://mirror.example.com/index/\""))
+	})
+}
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/buildpacks/libcnb"
@@ -63,4 +64,50 @@ func testCargo(t *testing.T, context spec.G, it spec.S) {
 		Expect(os.Getenv("CARGO_HOME")).To(Equal(layer.Path))
 		Expect(layer.BuildEnvironment).To(HaveKeyWithValue("CARGO_HOME.override", layer.Path))
 	})
+
+	it("declares registries in the cargo configuration", func() {
+		c := rustup.Cargo{Registries: []rustup.CargoRegistry{
+			{Name: "internal", Index: "https://cargo.example.com/index", Token: "secret"},
+		}}
+
+		layer, err := ctx.Layers.Layer("test-layer")
+		Expect(err).NotTo(HaveOccurred())
+
+		layer, err = c.Contribute(layer)
+		Expect(err).NotTo(HaveOccurred())
+
+		config, err := os.ReadFile(filepath.Join(layer.Path, "config.toml"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(config)).To(ContainSubstring("[registries.internal]"))
+		Expect(string(config)).NotTo(ContainSubstring("secret"))
+	})
+
+	it("removes files from a previous build", func() {
+		layer, err := ctx.Layers.Layer("test-layer")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(os.MkdirAll(layer.Path, 0755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(layer.Path, "config.toml"), []byte("# generated by the Paketo Buildpack for Rustup\n"), 0644)).To(Succeed())
+		Expect(os.Symlink("/does/not/exist", filepath.Join(layer.Path, "credentials.toml"))).To(Succeed())
+
+		layer, err = rustup.Cargo{}.Contribute(layer)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(filepath.Join(layer.Path, "config.toml")).NotTo(BeAnExistingFile())
+		_, err = os.Lstat(filepath.Join(layer.Path, "credentials.toml"))
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+
+	it("keeps a cargo configuration it did not generate", func() {
+		layer, err := ctx.Layers.Layer("test-layer")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(os.MkdirAll(layer.Path, 0755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(layer.Path, "config.toml"), []byte("[net]\noffline = true\n"), 0644)).To(Succeed())
+
+		layer, err = rustup.Cargo{}.Contribute(layer)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(filepath.Join(layer.Path, "config.toml")).To(BeARegularFile())
+	})
 }
@@ -17,25 +17,84 @@
 package rustup
 
 import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
 	"github.com/buildpacks/libcnb"
+	"github.com/paketo-buildpacks/libpak"
 )
 
 const (
 	PlanEntryRust = "rust"
 )
 
+// detectFiles are the files that make an application a Rust application
+var detectFiles = []string{"Cargo.toml", "rust-toolchain", "rust-toolchain.toml"}
+
+// toolFiles are the files of version managers that make an application a Rust application if they request the `rust`
+// tool
+var toolFiles = []string{"mise.toml", ".mise.toml", ".tool-versions"}
+
 type Detect struct {
 }
 
+// Detect passes if rustup is enabled. Rust applications, which have a `Cargo.toml` or a `rust-toolchain` file, or a
+// `mise.toml` or `.tool-versions` file requesting the `rust` tool, require Rust themselves. For all other applications
+// Rust is only provided, so the group only resolves if a later buildpack requires it.
 func (d Detect) Detect(context libcnb.DetectContext) (libcnb.DetectResult, error) {
-	return libcnb.DetectResult{
-		Pass: true,
-		Plans: []libcnb.BuildPlan{
-			{
-				Provides: []libcnb.BuildPlanProvide{
-					{Name: PlanEntryRust},
-				},
-			},
+	cr, err := libpak.NewConfigurationResolver(context.Buildpack, nil)
+	if err != nil {
+		return libcnb.DetectResult{}, fmt.Errorf("unable to create configuration resolver\n%w", err)
+	}
+
+	if !cr.ResolveBool("BP_RUSTUP_ENABLED") {
+		return libcnb.DetectResult{Pass: false}, nil
+	}
+
+	plan := libcnb.BuildPlan{
+		Provides: []libcnb.BuildPlanProvide{
+			{Name: PlanEntryRust},
 		},
+	}
+
+	ok, err := isRustApplication(context.Application.Path)
+	if err != nil {
+		return libcnb.DetectResult{}, err
+	}
+	if ok {
+		plan.Requires = []libcnb.BuildPlanRequire{
+			{Name: PlanEntryRust, Metadata: map[string]interface{}{"build": true}},
+		}
+	}
+
+	return libcnb.DetectResult{
+		Pass:  true,
+		Plans: []libcnb.BuildPlan{plan},
 	}, nil
 }
+
+// isRustApplication returns true if the application has one of detectFiles, or one of toolFiles requesting Rust
+func isRustApplication(appPath string) (bool, error) {
+	for _, name := range append(append([]string{}, detectFiles...), toolFiles...) {
+		path := filepath.Join(appPath, name)
+		if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return false, fmt.Errorf("unable to stat %s\n%w", path, err)
+		}
+
+		if !contains(toolFiles, name) {
+			return true, nil
+		}
+
+		// an invalid file requires Rust too, so that the build reports the error
+		if t, err := ParseToolchainFile(path); err != nil || t.Exists() {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
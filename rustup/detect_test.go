@@ -17,6 +17,8 @@
 package rustup_test
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/buildpacks/libcnb"
@@ -33,6 +35,19 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 		detect rustup.Detect
 	)
 
+	it.Before(func() {
+		ctx.Application.Path = t.TempDir()
+		ctx.Buildpack.Metadata = map[string]interface{}{
+			"configurations": []map[string]interface{}{
+				{
+					"name":    "BP_RUSTUP_ENABLED",
+					"default": "true",
+					"build":   true,
+				},
+			},
+		}
+	})
+
 	it("includes default build plan options", func() {
 		Expect(detect.Detect(ctx)).To(Equal(libcnb.DetectResult{
 			Pass: true,
@@ -45,4 +60,71 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 			},
 		}))
 	})
+
+	it("requires rust for a Cargo.toml", func() {
+		Expect(os.WriteFile(filepath.Join(ctx.Application.Path, "Cargo.toml"), []byte("[package]\nname = \"app\"\n"), 0644)).To(Succeed())
+
+		Expect(detect.Detect(ctx)).To(Equal(libcnb.DetectResult{
+			Pass: true,
+			Plans: []libcnb.BuildPlan{
+				{
+					Provides: []libcnb.BuildPlanProvide{
+						{Name: "rust"},
+					},
+					Requires: []libcnb.BuildPlanRequire{
+						{Name: "rust", Metadata: map[string]interface{}{"build": true}},
+					},
+				},
+			},
+		}))
+	})
+
+	it("requires rust for a toolchain file", func() {
+		Expect(os.WriteFile(filepath.Join(ctx.Application.Path, "rust-toolchain.toml"), []byte("[toolchain]\nchannel = \"stable\"\n"), 0644)).To(Succeed())
+
+		result, err := detect.Detect(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Plans[0].Requires).To(HaveLen(1))
+	})
+
+	it("requires rust for a mise.toml or .tool-versions with rust", func() {
+		for _, f := range []struct{ name, content string }{
+			{"mise.toml", "[tools]\nrust = \"1.78.0\"\n"},
+			{".mise.toml", "[tools]\nrust = \"stable\"\n"},
+			{".tool-versions", "rust 1.78.0\n"},
+		} {
+			path := filepath.Join(ctx.Application.Path, f.name)
+			Expect(os.WriteFile(path, []byte(f.content), 0644)).To(Succeed())
+
+			result, err := detect.Detect(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Plans[0].Requires).To(HaveLen(1), f.name)
+
+			Expect(os.Remove(path)).To(Succeed())
+		}
+	})
+
+	it("does not require rust for a .tool-versions without rust", func() {
+		Expect(os.WriteFile(filepath.Join(ctx.Application.Path, ".tool-versions"), []byte("nodejs 20.11.0\n"), 0644)).To(Succeed())
+
+		result, err := detect.Detect(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Plans[0].Requires).To(BeEmpty())
+	})
+
+	context("$BP_RUSTUP_ENABLED is false", func() {
+		it.Before(func() {
+			Expect(os.Setenv("BP_RUSTUP_ENABLED", "false")).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_RUSTUP_ENABLED")).To(Succeed())
+		})
+
+		it("fails", func() {
+			Expect(os.WriteFile(filepath.Join(ctx.Application.Path, "Cargo.toml"), []byte("[package]\nname = \"app\"\n"), 0644)).To(Succeed())
+
+			Expect(detect.Detect(ctx)).To(Equal(libcnb.DetectResult{Pass: false}))
+		})
+	})
 }
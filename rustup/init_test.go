@@ -25,11 +25,31 @@ import (
 
 func TestUnit(t *testing.T) {
 	suite := spec.New("Rustup", spec.Report(report.Terminal{}))
+	suite("Advisory", testAdvisory)
 	suite("Build", testBuild)
+	suite("CACertificates", testCACertificates)
 	suite("Detect", testDetect)
+	suite("Libc", testLibc)
+	suite("Lock", testLock)
+	suite("Manifest", testManifest)
+	suite("Mirror", testMirror)
+	suite("Plan", testPlan)
+	suite("ReleaseIndex", testReleaseIndex)
 	suite("Cargo", testCargo)
+	suite("CargoCredentials", testCargoCredentials)
+	suite("CargoRegistry", testCargoRegistry)
+	suite("CargoSource", testCargoSource)
 	suite("RustupInit", testRustupInit)
+	suite("SBOM", testSBOM)
 	suite("Rustup", testRustup)
 	suite("Rust", testRust)
+	suite("RustDist", testRustDist)
+	suite("RustVersion", testRustVersion)
+	suite("TargetPlatform", testTargetPlatform)
+	suite("Toolchain", testToolchain)
+	suite("ToolchainFile", testToolchainFile)
+	suite("ToolchainInfo", testToolchainInfo)
+	suite("UpdatePolicy", testUpdatePolicy)
+	suite("Version", testVersion)
 	suite.Run(t)
 }
@@ -0,0 +1,154 @@
+/*
+ * Copyright 2018-2024 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rustup
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// LibcAuto detects the libc of the build image
+	LibcAuto = "auto"
+
+	// LibcGNU is the GNU C library
+	LibcGNU = "gnu"
+
+	// LibcMusl is the musl C library
+	LibcMusl = "musl"
+)
+
+// muslDistros are distributions that use musl, all other known distributions use glibc
+var muslDistros = []string{"alpine", "chimera", "postmarketos"}
+
+// gnuDistros are distributions, or the ID_LIKE families of distributions, that use glibc
+var gnuDistros = []string{
+	"almalinux", "amzn", "arch", "centos", "debian", "fedora", "ol", "rhel", "rocky", "sles", "suse",
+	"ubuntu", "wolfi",
+}
+
+// LibcDetector detects the libc of the build image
+type LibcDetector struct {
+	// Root is the root of the file system of the build image, `/` if empty
+	Root string
+}
+
+// NewLibcDetector creates a detector for the running build image
+func NewLibcDetector() LibcDetector {
+	return LibcDetector{Root: "/"}
+}
+
+// Detect returns the libc of the build image, and why it was chosen. It uses the first of these that is conclusive:
+// the distribution in `/etc/os-release`, the distribution in `$CNB_TARGET_DISTRO_NAME` and the dynamic loader present
+// in the build image. If none are, GNU libc is returned.
+func (d LibcDetector) Detect() (string, string, error) {
+	if d.Root == "" {
+		d.Root = "/"
+	}
+
+	ids, err := d.osReleaseIDs()
+	if err != nil {
+		return "", "", err
+	}
+	if libc, ok := distroLibc(ids...); ok {
+		return libc, fmt.Sprintf("/etc/os-release identifies the distribution as %s", strings.Join(ids, ", ")), nil
+	}
+
+	if name, ok := os.LookupEnv("CNB_TARGET_DISTRO_NAME"); ok && name != "" {
+		if libc, ok := distroLibc(strings.ToLower(name)); ok {
+			return libc, fmt.Sprintf("$CNB_TARGET_DISTRO_NAME is %s", name), nil
+		}
+	}
+
+	for _, loader := range []struct {
+		libc    string
+		pattern string
+	}{
+		{LibcMusl, filepath.Join("lib", "ld-musl-*.so.1")},
+		{LibcGNU, filepath.Join("lib", "ld-linux*.so.*")},
+		{LibcGNU, filepath.Join("lib64", "ld-linux*.so.*")},
+	} {
+		matches, err := filepath.Glob(filepath.Join(d.Root, loader.pattern))
+		if err != nil {
+			return "", "", fmt.Errorf("unable to find dynamic loader %s\n%w", loader.pattern, err)
+		}
+		if len(matches) > 0 {
+			rel, _ := filepath.Rel(d.Root, matches[0])
+			return loader.libc, fmt.Sprintf("found dynamic loader /%s", filepath.ToSlash(rel)), nil
+		}
+	}
+
+	return LibcGNU, "unable to detect the libc of the build image, using the default", nil
+}
+
+// osReleaseIDs returns the ID and ID_LIKE values of `/etc/os-release`, or `/usr/lib/os-release` if it does not exist
+func (d LibcDetector) osReleaseIDs() ([]string, error) {
+	for _, path := range []string{
+		filepath.Join(d.Root, "etc", "os-release"),
+		filepath.Join(d.Root, "usr", "lib", "os-release"),
+	} {
+		file, err := os.Open(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("unable to open %s\n%w", path, err)
+		}
+		defer file.Close()
+
+		var ids []string
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+			if !ok || (key != "ID" && key != "ID_LIKE") {
+				continue
+			}
+
+			value = strings.ToLower(strings.Trim(value, `"'`))
+			if key == "ID" {
+				ids = append(strings.Fields(value), ids...)
+			} else {
+				ids = append(ids, strings.Fields(value)...)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("unable to read %s\n%w", path, err)
+		}
+
+		return ids, nil
+	}
+
+	return nil, nil
+}
+
+// distroLibc returns the libc of the first known distribution
+func distroLibc(names ...string) (string, bool) {
+	for _, name := range names {
+		if contains(muslDistros, name) {
+			return LibcMusl, true
+		}
+		if contains(gnuDistros, name) || strings.HasPrefix(name, "opensuse") {
+			return LibcGNU, true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,105 @@
+/*
+ * Copyright 2018-2024 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rustup_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/paketo-community/rustup/rustup"
+	"github.com/sclevine/spec"
+)
+
+func testLibc(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		detector rustup.LibcDetector
+	)
+
+	write := func(path string, content string) {
+		path = filepath.Join(detector.Root, path)
+		Expect(os.MkdirAll(filepath.Dir(path), 0755)).To(Succeed())
+		Expect(os.WriteFile(path, []byte(content), 0644)).To(Succeed())
+	}
+
+	it.Before(func() {
+		detector = rustup.LibcDetector{Root: t.TempDir()}
+	})
+
+	it.After(func() {
+		Expect(os.Unsetenv("CNB_TARGET_DISTRO_NAME")).To(Succeed())
+	})
+
+	it("detects musl from os-release", func() {
+		write("etc/os-release", "NAME=\"Alpine Linux\"\nID=alpine\nVERSION_ID=3.20.0\n")
+		write("lib/ld-linux-x86-64.so.2", "")
+
+		libc, reason, err := detector.Detect()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(libc).To(Equal("musl"))
+		Expect(reason).To(Equal("/etc/os-release identifies the distribution as alpine"))
+	})
+
+	it("detects gnu from the os-release family", func() {
+		write("usr/lib/os-release", "ID=\"pop\"\nID_LIKE=\"ubuntu debian\"\n")
+
+		libc, reason, err := detector.Detect()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(libc).To(Equal("gnu"))
+		Expect(reason).To(Equal("/etc/os-release identifies the distribution as pop, ubuntu, debian"))
+	})
+
+	it("detects the libc from the target distribution", func() {
+		write("etc/os-release", "ID=custom\n")
+		Expect(os.Setenv("CNB_TARGET_DISTRO_NAME", "Alpine")).To(Succeed())
+
+		libc, reason, err := detector.Detect()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(libc).To(Equal("musl"))
+		Expect(reason).To(Equal("$CNB_TARGET_DISTRO_NAME is Alpine"))
+	})
+
+	it("detects musl from the dynamic loader", func() {
+		write("etc/os-release", "ID=custom\n")
+		Expect(os.Setenv("CNB_TARGET_DISTRO_NAME", "custom")).To(Succeed())
+		write("lib/ld-musl-aarch64.so.1", "")
+
+		libc, reason, err := detector.Detect()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(libc).To(Equal("musl"))
+		Expect(reason).To(Equal("found dynamic loader /lib/ld-musl-aarch64.so.1"))
+	})
+
+	it("detects gnu from the dynamic loader", func() {
+		write("lib64/ld-linux-x86-64.so.2", "")
+
+		libc, reason, err := detector.Detect()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(libc).To(Equal("gnu"))
+		Expect(reason).To(Equal("found dynamic loader /lib64/ld-linux-x86-64.so.2"))
+	})
+
+	it("defaults to gnu", func() {
+		libc, reason, err := detector.Detect()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(libc).To(Equal("gnu"))
+		Expect(reason).To(ContainSubstring("unable to detect"))
+	})
+}
@@ -0,0 +1,167 @@
+/*
+ * Copyright 2018-2024 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rustup
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+const (
+	// LockFileName is the name of the toolchain lock file in the application
+	LockFileName = "rust-toolchain.lock"
+
+	// LockModeOff neither reads nor writes the lock file
+	LockModeOff = "off"
+
+	// LockModeRead installs the toolchains pinned in the lock file and fails if it is missing
+	LockModeRead = "read"
+
+	// LockModeWrite writes the installed toolchains to the lock file
+	LockModeWrite = "write"
+
+	// LockModeAuto reads the lock file if it exists and writes it otherwise
+	LockModeAuto = "auto"
+)
+
+var validLockModes = []string{LockModeOff, LockModeRead, LockModeWrite, LockModeAuto}
+
+// ToolchainLock is the content of a toolchain lock file
+type ToolchainLock struct {
+	Toolchains []LockedToolchain `toml:"toolchain"`
+}
+
+// LockedToolchain is the exact release a requested toolchain resolved to
+type LockedToolchain struct {
+	// Name is the requested toolchain, like `stable`
+	Name string `toml:"name"`
+
+	// Channel is the resolved channel, like `1.78.0` or `nightly-2024-05-02`
+	Channel string `toml:"channel"`
+
+	// Date is the date of the channel manifest
+	Date string `toml:"date"`
+
+	// Hash is the SHA256 of the channel manifest
+	Hash string `toml:"hash"`
+}
+
+// ParseLockMode validates a lock mode, an empty value is LockModeOff
+func ParseLockMode(val string) (string, error) {
+	val = strings.TrimSpace(strings.ToLower(val))
+	if val == "" {
+		return LockModeOff, nil
+	}
+
+	if !contains(validLockModes, val) {
+		return "", fmt.Errorf("invalid toolchain lock mode %q, must be one of %s", val, strings.Join(validLockModes, ", "))
+	}
+
+	return val, nil
+}
+
+// ReadToolchainLock reads a lock file. It returns false if the file does not exist.
+func ReadToolchainLock(path string) (ToolchainLock, bool, error) {
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return ToolchainLock{}, false, nil
+	} else if err != nil {
+		return ToolchainLock{}, false, fmt.Errorf("unable to read %s\n%w", path, err)
+	}
+
+	var l ToolchainLock
+	if _, err := toml.Decode(string(raw), &l); err != nil {
+		return ToolchainLock{}, false, fmt.Errorf("unable to parse %s\n%w", path, err)
+	}
+
+	for _, t := range l.Toolchains {
+		if t.Name == "" || t.Channel == "" || t.Hash == "" {
+			return ToolchainLock{}, false, fmt.Errorf("%s has an incomplete toolchain entry %+v", path, t)
+		}
+	}
+
+	return l, true, nil
+}
+
+// Write writes the lock file
+func (l ToolchainLock) Write(path string) error {
+	buf := &bytes.Buffer{}
+	buf.WriteString("# generated by the Paketo Buildpack for Rustup, commit this file to install the same toolchains\n")
+	if err := toml.NewEncoder(buf).Encode(l); err != nil {
+		return fmt.Errorf("unable to encode toolchain lock\n%w", err)
+	}
+
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("unable to write %s\n%w", path, err)
+	}
+
+	return nil
+}
+
+// Find returns the locked toolchain with the given name
+func (l ToolchainLock) Find(name string) (LockedToolchain, bool) {
+	for _, t := range l.Toolchains {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return LockedToolchain{}, false
+}
+
+// Pin sets the pinned channel of each toolchain to the channel in the lock file, found by the LockName of the
+// toolchain. Linked toolchains are not pinned. If strict is true, every other toolchain must be in the lock file.
+func (l ToolchainLock) Pin(toolchains []Toolchain, strict bool) ([]Toolchain, error) {
+	var pinned []Toolchain
+
+	for _, t := range toolchains {
+		if !t.IsLinked() {
+			if locked, ok := l.Find(t.LockName()); ok {
+				t.Pinned = locked.Channel
+			} else if strict {
+				return nil, fmt.Errorf("toolchain %s is not in %s, write the lock file again", t.LockName(), LockFileName)
+			}
+		}
+		pinned = append(pinned, t)
+	}
+
+	return pinned, nil
+}
+
+// CheckToolchainFile returns an error if the toolchain of a file rustup follows is pinned to a different release, like
+// `stable` to `1.78.0`. rustup resolves the channel of the file again when cargo runs, so the pinned release would not
+// be used.
+func CheckToolchainFile(file ToolchainFile, toolchains []Toolchain) error {
+	if !file.IsRustupFile() {
+		return nil
+	}
+
+	for _, t := range toolchains {
+		if t.Name == file.Name() && t.Pinned != "" && t.Pinned != t.Name {
+			return fmt.Errorf("%s requests %s, which rustup resolves again instead of using %s from %s, set the channel "+
+				"in %s to %s", filepath.Base(file.Path), t.Name, t.Pinned, LockFileName, filepath.Base(file.Path), t.Pinned)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,144 @@
+/*
+ * Copyright 2018-2024 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rustup_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/paketo-community/rustup/rustup"
+	"github.com/sclevine/spec"
+)
+
+func testLock(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		appPath string
+	)
+
+	it.Before(func() {
+		var err error
+
+		appPath, err = os.MkdirTemp("", "lock")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(appPath)).To(Succeed())
+	})
+
+	it("parses lock modes", func() {
+		Expect(rustup.ParseLockMode("")).To(Equal(rustup.LockModeOff))
+		Expect(rustup.ParseLockMode("Auto")).To(Equal(rustup.LockModeAuto))
+
+		_, err := rustup.ParseLockMode("maybe")
+		Expect(err).To(MatchError(ContainSubstring("invalid toolchain lock mode \"maybe\"")))
+	})
+
+	it("returns false without a lock file", func() {
+		_, ok, err := rustup.ReadToolchainLock(filepath.Join(appPath, rustup.LockFileName))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeFalse())
+	})
+
+	it("writes and reads a lock file", func() {
+		path := filepath.Join(appPath, rustup.LockFileName)
+		lock := rustup.ToolchainLock{Toolchains: []rustup.LockedToolchain{
+			{Name: "stable", Channel: "1.78.0", Date: "2024-05-02", Hash: "abc"},
+			{Name: "nightly", Channel: "nightly-2024-05-02", Date: "2024-05-02", Hash: "def"},
+		}}
+
+		Expect(lock.Write(path)).To(Succeed())
+
+		l, ok, err := rustup.ReadToolchainLock(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeTrue())
+		Expect(l).To(Equal(lock))
+	})
+
+	it("rejects an incomplete entry", func() {
+		path := filepath.Join(appPath, rustup.LockFileName)
+		Expect(os.WriteFile(path, []byte("[[toolchain]]\nname = \"stable\"\n"), 0644)).To(Succeed())
+
+		_, _, err := rustup.ReadToolchainLock(path)
+		Expect(err).To(MatchError(ContainSubstring("incomplete toolchain entry")))
+	})
+
+	context("Pin", func() {
+		lock := rustup.ToolchainLock{Toolchains: []rustup.LockedToolchain{
+			{Name: "stable", Channel: "1.78.0", Hash: "abc"},
+		}}
+
+		it("pins locked toolchains", func() {
+			toolchains, err := lock.Pin([]rustup.Toolchain{
+				{Name: "stable", Profile: "minimal"},
+				{Name: "local", Path: "/toolchain"},
+			}, true)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(toolchains).To(Equal([]rustup.Toolchain{
+				{Name: "stable", Profile: "minimal", Pinned: "1.78.0"},
+				{Name: "local", Path: "/toolchain"},
+			}))
+			Expect(toolchains[0].Channel()).To(Equal("1.78.0"))
+		})
+
+		it("pins toolchains resolved from a range by the range", func() {
+			lock := rustup.ToolchainLock{Toolchains: []rustup.LockedToolchain{
+				{Name: "1.78.*", Channel: "1.78.0", Hash: "abc"},
+			}}
+
+			toolchains, err := lock.Pin([]rustup.Toolchain{{Name: "1.78.1", Range: "1.78.*", Profile: "minimal"}}, true)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(toolchains[0].Channel()).To(Equal("1.78.0"))
+		})
+
+		it("rejects a toolchain missing from the lock file", func() {
+			_, err := lock.Pin([]rustup.Toolchain{{Name: "beta", Profile: "minimal"}}, true)
+			Expect(err).To(MatchError(ContainSubstring("toolchain beta is not in rust-toolchain.lock")))
+		})
+	})
+	context("CheckToolchainFile", func() {
+		toolchains := []rustup.Toolchain{
+			{Name: "stable", Profile: "minimal", Pinned: "1.78.0"},
+			{Name: "1.77.2", Profile: "minimal", Pinned: "1.77.2"},
+		}
+
+		it("rejects a floating channel in a file rustup follows", func() {
+			file := rustup.ToolchainFile{Path: filepath.Join(appPath, "rust-toolchain.toml"), Channel: "stable"}
+
+			Expect(rustup.CheckToolchainFile(file, toolchains)).To(MatchError(
+				"rust-toolchain.toml requests stable, which rustup resolves again instead of using 1.78.0 from " +
+					"rust-toolchain.lock, set the channel in rust-toolchain.toml to 1.78.0"))
+		})
+
+		it("accepts an exact release", func() {
+			file := rustup.ToolchainFile{Path: filepath.Join(appPath, "rust-toolchain"), Channel: "1.77.2"}
+
+			Expect(rustup.CheckToolchainFile(file, toolchains)).To(Succeed())
+		})
+
+		it("accepts files rustup does not follow", func() {
+			file := rustup.ToolchainFile{Path: filepath.Join(appPath, ".tool-versions"), Channel: "stable"}
+
+			Expect(rustup.CheckToolchainFile(file, toolchains)).To(Succeed())
+			Expect(rustup.CheckToolchainFile(rustup.ToolchainFile{}, toolchains)).To(Succeed())
+		})
+	})
+}
@@ -0,0 +1,241 @@
+/*
+ * Copyright 2018-2024 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rustup
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/BurntSushi/toml"
+)
+
+// ChannelManifest is the channel manifest rustup stores with each installed toolchain
+type ChannelManifest struct {
+	// Date is the date the channel was released
+	Date string `toml:"date"`
+
+	// Packages are the packages available in the channel, keyed by package name
+	Packages map[string]ManifestPackage `toml:"pkg"`
+
+	// Hash is the SHA256 of the manifest file
+	Hash string `toml:"-"`
+}
+
+// ManifestPackage is a single package in a channel manifest
+type ManifestPackage struct {
+	// Version is the full version of the package, like `1.78.0 (9b00956e5 2024-04-29)`
+	Version string `toml:"version"`
+
+	// Targets are the builds of the package, keyed by target triple
+	Targets map[string]ManifestTarget `toml:"target"`
+}
+
+// ManifestTarget is the build of a package for a single target
+type ManifestTarget struct {
+	Available bool   `toml:"available"`
+	Hash      string `toml:"hash"`
+	XZHash    string `toml:"xz_hash"`
+}
+
+// ReadChannelManifest reads a channel manifest file
+func ReadChannelManifest(path string) (ChannelManifest, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return ChannelManifest{}, fmt.Errorf("unable to read %s\n%w", path, err)
+	}
+
+	var m ChannelManifest
+	if err := toml.Unmarshal(raw, &m); err != nil {
+		return ChannelManifest{}, fmt.Errorf("unable to parse %s\n%w", path, err)
+	}
+	m.Hash = fmt.Sprintf("%x", sha256.Sum256(raw))
+
+	return m, nil
+}
+
+// ResolvedChannel returns the exact channel a toolchain installed from this manifest can be reinstalled with. Beta and
+// nightly toolchains resolve to the dated channel, like `nightly-2024-05-02`, and all other toolchains to the Rust
+// version, like `1.78.0`.
+func (m ChannelManifest) ResolvedChannel(name string) string {
+	for _, prefix := range []string{"beta", "nightly"} {
+		if (name == prefix || strings.HasPrefix(name, prefix+"-")) && m.Date != "" {
+			return fmt.Sprintf("%s-%s", prefix, m.Date)
+		}
+	}
+
+	if v := m.RustVersion(); v != "" {
+		return v
+	}
+
+	return name
+}
+
+// RustVersion returns the version of Rust in this manifest, like `1.78.0` or `1.80.0-nightly`
+func (m ChannelManifest) RustVersion() string {
+	if fields := strings.Fields(m.Packages["rust"].Version); len(fields) > 0 {
+		return fields[0]
+	}
+
+	return ""
+}
+
+// ToolchainDirectory returns the directory in $RUSTUP_HOME a toolchain is installed to. Rustup appends the host triple
+// to channel names, so `stable` is installed to `toolchains/stable-x86_64-unknown-linux-gnu`.
+func ToolchainDirectory(rustupHome string, name string) (string, bool, error) {
+	toolchains := filepath.Join(rustupHome, "toolchains")
+
+	entries, err := os.ReadDir(toolchains)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", false, nil
+	} else if err != nil {
+		return "", false, fmt.Errorf("unable to read %s\n%w", toolchains, err)
+	}
+
+	for _, e := range entries {
+		if e.Name() == name {
+			return filepath.Join(toolchains, e.Name()), true, nil
+		}
+	}
+
+	// a date following the name is a different toolchain, `nightly` must not match `nightly-2024-05-01-<host>`
+	for _, e := range entries {
+		if rest := strings.TrimPrefix(e.Name(), name+"-"); rest != e.Name() && rest != "" && !unicode.IsDigit(rune(rest[0])) {
+			return filepath.Join(toolchains, e.Name()), true, nil
+		}
+	}
+
+	return "", false, nil
+}
+
+// InstalledManifest reads the channel manifest of an installed toolchain. It returns false if the toolchain is not
+// installed or has no manifest, which is the case for linked toolchains.
+func InstalledManifest(rustupHome string, name string) (ChannelManifest, bool, error) {
+	dir, ok, err := ToolchainDirectory(rustupHome, name)
+	if err != nil || !ok {
+		return ChannelManifest{}, false, err
+	}
+
+	path := filepath.Join(dir, "lib", "rustlib", "multirust-channel-manifest.toml")
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		return ChannelManifest{}, false, nil
+	} else if err != nil {
+		return ChannelManifest{}, false, fmt.Errorf("unable to stat %s\n%w", path, err)
+	}
+
+	m, err := ReadChannelManifest(path)
+	if err != nil {
+		return ChannelManifest{}, false, err
+	}
+
+	return m, true, nil
+}
+
+// InstalledComponent is a component of an installed toolchain, like `rustc` or `rust-std` for a target
+type InstalledComponent struct {
+	// Name is the package name with the `-preview` suffix removed, like `clippy`
+	Name string
+
+	// Target is the target triple of the component, empty for target independent components like `rust-src`
+	Target string
+
+	// Version is the version of the package, like `1.78.0`
+	Version string
+
+	// SHA256 is the hash of the archive the component was installed from
+	SHA256 string
+}
+
+// InstalledComponents returns the components listed in `lib/rustlib/components` of an installed toolchain, with the
+// version and hash from its channel manifest. Components missing from the channel manifest are returned separately, so
+// that a toolchain installed by rustup is never rejected because of them.
+func InstalledComponents(toolchainDir string, manifest ChannelManifest) ([]InstalledComponent, []string, error) {
+	path := filepath.Join(toolchainDir, "lib", "rustlib", "components")
+
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, nil
+	} else if err != nil {
+		return nil, nil, fmt.Errorf("unable to read %s\n%w", path, err)
+	}
+
+	var (
+		components []InstalledComponent
+		unknown    []string
+	)
+	for _, line := range strings.Split(string(raw), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		component, ok := manifest.component(line)
+		if !ok {
+			unknown = append(unknown, line)
+			continue
+		}
+		components = append(components, component)
+	}
+
+	return components, unknown, nil
+}
+
+// component finds the package and target of a component name like `rust-std-wasm32-unknown-unknown`. Package names
+// can contain dashes, so the longest package name with a matching target wins.
+func (m ChannelManifest) component(name string) (InstalledComponent, bool) {
+	var (
+		found InstalledComponent
+		ok    bool
+	)
+
+	for pkgName, pkg := range m.Packages {
+		target := ""
+		if name == pkgName {
+			target = "*"
+		} else if strings.HasPrefix(name, pkgName+"-") {
+			target = strings.TrimPrefix(name, pkgName+"-")
+		} else {
+			continue
+		}
+
+		t, exists := pkg.Targets[target]
+		if !exists || (ok && len(pkgName) < len(found.Name)) {
+			continue
+		}
+
+		found = InstalledComponent{
+			Name:   pkgName,
+			Target: strings.TrimPrefix(target, "*"),
+			SHA256: t.XZHash,
+		}
+		if found.SHA256 == "" {
+			found.SHA256 = t.Hash
+		}
+		if fields := strings.Fields(pkg.Version); len(fields) > 0 {
+			found.Version = fields[0]
+		}
+		ok = true
+	}
+
+	found.Name = strings.TrimSuffix(found.Name, "-preview")
+	return found, ok
+}
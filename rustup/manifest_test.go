@@ -0,0 +1,208 @@
+/*
+ * Copyright 2018-2024 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rustup_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/paketo-community/rustup/rustup"
+	"github.com/sclevine/spec"
+)
+
+func testManifest(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		rustupHome string
+	)
+
+	it.Before(func() {
+		var err error
+
+		rustupHome, err = os.MkdirTemp("", "rustup-home")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(rustupHome)).To(Succeed())
+	})
+
+	install := func(name string) string {
+		dir := filepath.Join(rustupHome, "toolchains", name, "lib", "rustlib")
+		Expect(os.MkdirAll(dir, 0755)).To(Succeed())
+
+		raw, err := os.ReadFile(filepath.Join("testdata", "multirust-channel-manifest.toml"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.WriteFile(filepath.Join(dir, "multirust-channel-manifest.toml"), raw, 0644)).To(Succeed())
+
+		return filepath.Join(rustupHome, "toolchains", name)
+	}
+
+	it("reads a channel manifest", func() {
+		m, err := rustup.ReadChannelManifest(filepath.Join("testdata", "multirust-channel-manifest.toml"))
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(m.Date).To(Equal("2024-05-02"))
+		Expect(m.Hash).To(HaveLen(64))
+		Expect(m.Packages["rust"].Version).To(Equal("1.78.0 (9b00956e5 2024-04-29)"))
+		Expect(m.Packages["rust-std"].Targets).To(HaveKey("wasm32-unknown-unknown"))
+		Expect(m.Packages["rust-std"].Targets["wasm32-unknown-unknown"].Available).To(BeTrue())
+	})
+
+	it("resolves the exact channel", func() {
+		m, err := rustup.ReadChannelManifest(filepath.Join("testdata", "multirust-channel-manifest.toml"))
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(m.ResolvedChannel("stable")).To(Equal("1.78.0"))
+		Expect(m.ResolvedChannel("1.78")).To(Equal("1.78.0"))
+		Expect(m.ResolvedChannel("nightly")).To(Equal("nightly-2024-05-02"))
+		Expect(m.ResolvedChannel("beta")).To(Equal("beta-2024-05-02"))
+	})
+
+	context("ToolchainDirectory", func() {
+		it("returns false without toolchains", func() {
+			_, ok, err := rustup.ToolchainDirectory(rustupHome, "stable")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeFalse())
+		})
+
+		it("matches a channel with the host triple", func() {
+			dir := install("stable-x86_64-unknown-linux-gnu")
+
+			d, ok, err := rustup.ToolchainDirectory(rustupHome, "stable")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			Expect(d).To(Equal(dir))
+		})
+
+		it("does not match a dated channel", func() {
+			install("nightly-2024-05-01-x86_64-unknown-linux-gnu")
+
+			_, ok, err := rustup.ToolchainDirectory(rustupHome, "nightly")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeFalse())
+		})
+
+		it("matches an exact name", func() {
+			dir := install("local")
+
+			d, ok, err := rustup.ToolchainDirectory(rustupHome, "local")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			Expect(d).To(Equal(dir))
+		})
+	})
+
+	context("InstalledManifest", func() {
+		it("reads the manifest of an installed toolchain", func() {
+			install("1.78.0-x86_64-unknown-linux-gnu")
+
+			m, ok, err := rustup.InstalledManifest(rustupHome, "1.78.0")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			Expect(m.Date).To(Equal("2024-05-02"))
+		})
+
+		it("returns false for a toolchain without a manifest", func() {
+			Expect(os.MkdirAll(filepath.Join(rustupHome, "toolchains", "local"), 0755)).To(Succeed())
+
+			_, ok, err := rustup.InstalledManifest(rustupHome, "local")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	context("InstalledComponents", func() {
+		var m rustup.ChannelManifest
+
+		it.Before(func() {
+			var err error
+
+			m, err = rustup.ReadChannelManifest(filepath.Join("testdata", "multirust-channel-manifest.toml"))
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		it("returns nothing without a components file", func() {
+			dir := install("stable-x86_64-unknown-linux-gnu")
+
+			components, unknown, err := rustup.InstalledComponents(dir, m)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(components).To(BeEmpty())
+			Expect(unknown).To(BeEmpty())
+		})
+
+		it("reads the installed components", func() {
+			dir := install("stable-x86_64-unknown-linux-gnu")
+			Expect(os.WriteFile(filepath.Join(dir, "lib", "rustlib", "components"), []byte(`rustc-x86_64-unknown-linux-gnu
+rust-std-x86_64-unknown-linux-gnu
+rust-std-wasm32-unknown-unknown
+clippy-preview-x86_64-unknown-linux-gnu
+rust-src
+`), 0644)).To(Succeed())
+
+			components, unknown, err := rustup.InstalledComponents(dir, m)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(unknown).To(BeEmpty())
+			Expect(components).To(Equal([]rustup.InstalledComponent{
+				{
+					Name:    "rustc",
+					Target:  "x86_64-unknown-linux-gnu",
+					Version: "1.78.0",
+					SHA256:  "e59ce26bfc09de0c2d08192a3b4c5d6e7f8091a2b3c4d5e6f708192a3b4c5d6e",
+				},
+				{
+					Name:    "rust-std",
+					Target:  "x86_64-unknown-linux-gnu",
+					Version: "1.78.0",
+					SHA256:  "c37ac049dae7bcea0be6f708192a3b4c5d6e7f8091a2b3c4d5e6f708192a3b4c",
+				},
+				{
+					Name:    "rust-std",
+					Target:  "wasm32-unknown-unknown",
+					Version: "1.78.0",
+					SHA256:  "a15eae27b8c5fac8c9c4d5e6f708192a3b4c5d6e7f8091a2b3c4d5e6f708192a",
+				},
+				{
+					Name:    "clippy",
+					Target:  "x86_64-unknown-linux-gnu",
+					Version: "0.1.78",
+					SHA256:  "6d1a6ae3d4e1b6e4e5f8091a2b3c4d5e6f708192a3b4c5d6e7f8091a2b3c4d5e",
+				},
+				{
+					Name:    "rust-src",
+					Version: "1.78.0",
+					SHA256:  "07b1c2d3e4f5061728394a5b6c7d8e9f0a1b2c3d4e5f60718293a4b5c6d7e8f9",
+				},
+			}))
+		})
+
+		it("returns components missing from the manifest separately", func() {
+			dir := install("stable-x86_64-unknown-linux-gnu")
+			Expect(os.WriteFile(filepath.Join(dir, "lib", "rustlib", "components"),
+				[]byte("miri-x86_64-unknown-linux-gnu\nrust-src\n"), 0644)).To(Succeed())
+
+			components, unknown, err := rustup.InstalledComponents(dir, m)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(components).To(HaveLen(1))
+			Expect(components[0].Name).To(Equal("rust-src"))
+			Expect(unknown).To(Equal([]string{"miri-x86_64-unknown-linux-gnu"}))
+		})
+	})
+}
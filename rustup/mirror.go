@@ -0,0 +1,169 @@
+/*
+ * Copyright 2018-2024 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rustup
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/buildpacks/libcnb"
+	"github.com/paketo-buildpacks/libpak"
+	"github.com/paketo-buildpacks/libpak/bindings"
+)
+
+// Mirror is an alternative location to download Rust toolchains and rustup updates from
+type Mirror struct {
+	// DistServer is the URL toolchains are downloaded from, in place of https://static.rust-lang.org
+	DistServer string
+
+	// UpdateRoot is the URL rustup updates are downloaded from, in place of https://static.rust-lang.org/rustup
+	UpdateRoot string
+}
+
+// ResolveMirror returns the mirror configured with $BP_RUSTUP_DIST_SERVER and $BP_RUSTUP_UPDATE_ROOT. Values that are
+// not configured are read from the `dist-server` and `update-root` keys of a binding of type `rustup`.
+func ResolveMirror(cr libpak.ConfigurationResolver, binds libcnb.Bindings) (Mirror, error) {
+	var m Mirror
+	m.DistServer, _ = cr.Resolve("BP_RUSTUP_DIST_SERVER")
+	m.UpdateRoot, _ = cr.Resolve("BP_RUSTUP_UPDATE_ROOT")
+
+	binding, ok, err := bindings.ResolveOne(binds, bindings.OfType("rustup"))
+	if err != nil {
+		return Mirror{}, fmt.Errorf("unable to resolve binding\n%w", err)
+	}
+	if ok {
+		if m.DistServer == "" {
+			m.DistServer = binding.Secret["dist-server"]
+		}
+		if m.UpdateRoot == "" {
+			m.UpdateRoot = binding.Secret["update-root"]
+		}
+	}
+
+	m.DistServer = strings.TrimRight(strings.TrimSpace(m.DistServer), "/")
+	m.UpdateRoot = strings.TrimRight(strings.TrimSpace(m.UpdateRoot), "/")
+
+	for _, u := range []string{m.DistServer, m.UpdateRoot} {
+		if u == "" {
+			continue
+		}
+
+		parsed, err := url.Parse(u)
+		if err != nil || !contains([]string{"http", "https", "file"}, parsed.Scheme) {
+			return Mirror{}, fmt.Errorf("invalid mirror URL %q, must be an http, https or file URL", redact(u))
+		}
+	}
+
+	return m, nil
+}
+
+// IsSet returns true if either URL is configured
+func (m Mirror) IsSet() bool {
+	return m.DistServer != "" || m.UpdateRoot != ""
+}
+
+// Setenv sets $RUSTUP_DIST_SERVER and $RUSTUP_UPDATE_ROOT for rustup run by this buildpack
+func (m Mirror) Setenv() error {
+	if m.DistServer != "" {
+		if err := os.Setenv("RUSTUP_DIST_SERVER", m.DistServer); err != nil {
+			return fmt.Errorf("unable to set $RUSTUP_DIST_SERVER\n%w", err)
+		}
+	}
+
+	if m.UpdateRoot != "" {
+		if err := os.Setenv("RUSTUP_UPDATE_ROOT", m.UpdateRoot); err != nil {
+			return fmt.Errorf("unable to set $RUSTUP_UPDATE_ROOT\n%w", err)
+		}
+	}
+
+	return nil
+}
+
+// Override sets $RUSTUP_DIST_SERVER and $RUSTUP_UPDATE_ROOT for subsequent buildpacks. URLs with credentials are only
+// set for rustup run by this buildpack, so they are neither written to the cached layer nor exported. The names of the
+// variables that are not exported are returned.
+func (m Mirror) Override(env libcnb.Environment) []string {
+	var skipped []string
+
+	for _, e := range []struct{ name, value string }{
+		{"RUSTUP_DIST_SERVER", m.DistServer},
+		{"RUSTUP_UPDATE_ROOT", m.UpdateRoot},
+	} {
+		if e.value == "" {
+			continue
+		}
+
+		if hasCredentials(e.value) {
+			skipped = append(skipped, e.name)
+			continue
+		}
+
+		env.Override(e.name, e.value)
+	}
+
+	return skipped
+}
+
+// Metadata returns the mirror for layer metadata, with credentials removed
+func (m Mirror) Metadata() map[string]interface{} {
+	return map[string]interface{}{
+		"dist-server": redact(m.DistServer),
+		"update-root": redact(m.UpdateRoot),
+	}
+}
+
+func (m Mirror) String() string {
+	var s []string
+	if m.DistServer != "" {
+		s = append(s, fmt.Sprintf("dist-server=%s", redact(m.DistServer)))
+	}
+	if m.UpdateRoot != "" {
+		s = append(s, fmt.Sprintf("update-root=%s", redact(m.UpdateRoot)))
+	}
+	return strings.Join(s, " ")
+}
+
+// hasCredentials returns true if a URL has user info or a query, either of which can carry a token
+func hasCredentials(u string) bool {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return true
+	}
+	return parsed.User != nil || parsed.RawQuery != ""
+}
+
+// redact removes the password, a token used as user name and the query from a URL, so they are not written to logs or
+// metadata
+func redact(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return u
+	}
+
+	if parsed.User != nil {
+		if _, ok := parsed.User.Password(); !ok {
+			parsed.User = url.User("xxxxx")
+		}
+	}
+	if parsed.RawQuery != "" {
+		parsed.RawQuery = "xxxxx"
+	}
+
+	return parsed.Redacted()
+}
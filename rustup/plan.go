@@ -0,0 +1,156 @@
+/*
+ * Copyright 2018-2024 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rustup
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/buildpacks/libcnb"
+	"github.com/heroku/color"
+	"github.com/paketo-buildpacks/libpak/bard"
+)
+
+// PlanToolchain merges the toolchain requested in the metadata of each `rust` build plan entry, like
+// `{toolchain = "nightly", profile = "minimal", components = ["rust-src"], targets = ["wasm32-unknown-unknown"]}`.
+// Components and targets are combined and the most complete profile is kept. Entries must not request different
+// toolchains. The name of the returned toolchain is empty if no entry requests one.
+func PlanToolchain(entries []libcnb.BuildpackPlanEntry) (Toolchain, error) {
+	var merged Toolchain
+
+	for _, entry := range entries {
+		if entry.Name != PlanEntryRust {
+			continue
+		}
+
+		t, err := planEntryToolchain(entry.Metadata)
+		if err != nil {
+			return Toolchain{}, err
+		}
+
+		if t.Name != "" && merged.Name != "" && t.Name != merged.Name {
+			return Toolchain{}, fmt.Errorf("build plan entries request conflicting toolchains %s and %s", merged.Name, t.Name)
+		}
+
+		merged = merged.Merge(t)
+		if t.Name != "" {
+			merged.Name = t.Name
+		}
+	}
+
+	return merged, nil
+}
+
+// MergePlanToolchain installs the components, targets and profile requested in the build plan with the configured
+// toolchains. They are merged into the toolchain the plan requests, or into the default toolchain if it requests none.
+// A requested toolchain that is not configured is installed next to the configured ones, with the given profile unless
+// the plan requests one. Linked toolchains are not changed.
+func MergePlanToolchain(logger bard.Logger, toolchains []Toolchain, plan Toolchain, defaultToolchain string, profile string) []Toolchain {
+	if plan.Name == "" && plan.Profile == "" && len(plan.Components) == 0 && len(plan.Targets) == 0 {
+		return toolchains
+	}
+
+	name := plan.Name
+	if name == "" {
+		name = defaultToolchain
+	}
+
+	merged := append([]Toolchain{}, toolchains...)
+	for i, t := range merged {
+		if t.Name != name {
+			continue
+		}
+
+		if t.IsLinked() {
+			logger.Bodyf("Skipping build plan targets and components for linked toolchain %s", t.Name)
+		} else {
+			merged[i] = t.Merge(plan)
+		}
+		return merged
+	}
+
+	logger.Bodyf("%s the build plan requests toolchain %s, but the application configures %s, installing both",
+		color.YellowString("Warning:"), plan.Name, defaultToolchain)
+	if plan.Profile == "" {
+		plan.Profile = profile
+	}
+
+	return append(merged, plan)
+}
+
+func planEntryToolchain(metadata map[string]interface{}) (Toolchain, error) {
+	var t Toolchain
+
+	for key, value := range metadata {
+		switch key {
+		case "toolchain":
+			s, ok := value.(string)
+			if !ok || strings.TrimSpace(s) == "" || strings.ContainsAny(strings.TrimSpace(s), " \t") {
+				return Toolchain{}, fmt.Errorf("invalid toolchain %v in build plan entry", value)
+			}
+			t.Name = strings.TrimSpace(s)
+		case "profile":
+			s, ok := value.(string)
+			if !ok || !contains(validProfiles, s) {
+				return Toolchain{}, fmt.Errorf("invalid profile %v in build plan entry, must be one of %s",
+					value, strings.Join(validProfiles, ", "))
+			}
+			t.Profile = s
+		case "components":
+			s, err := planList(value)
+			if err != nil {
+				return Toolchain{}, fmt.Errorf("invalid components in build plan entry\n%w", err)
+			}
+			if t.Components, err = ParseComponents(s); err != nil {
+				return Toolchain{}, fmt.Errorf("invalid components in build plan entry\n%w", err)
+			}
+		case "targets":
+			s, err := planList(value)
+			if err != nil {
+				return Toolchain{}, fmt.Errorf("invalid targets in build plan entry\n%w", err)
+			}
+			if t.Targets, err = ParseTargets(s); err != nil {
+				return Toolchain{}, fmt.Errorf("invalid targets in build plan entry\n%w", err)
+			}
+		}
+	}
+
+	return t, nil
+}
+
+// planList joins a list of strings from build plan metadata, which is either a TOML array or a comma or space
+// separated string
+func planList(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case []string:
+		return strings.Join(v, ","), nil
+	case []interface{}:
+		var s []string
+		for _, e := range v {
+			str, ok := e.(string)
+			if !ok {
+				return "", fmt.Errorf("%v is not a string", e)
+			}
+			s = append(s, str)
+		}
+		return strings.Join(s, ","), nil
+	default:
+		return "", fmt.Errorf("%v must be a list of strings", value)
+	}
+}
@@ -0,0 +1,159 @@
+/*
+ * Copyright 2018-2024 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rustup_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/buildpacks/libcnb"
+	. "github.com/onsi/gomega"
+	"github.com/paketo-buildpacks/libpak/bard"
+	"github.com/paketo-community/rustup/rustup"
+	"github.com/sclevine/spec"
+)
+
+func testPlan(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+	)
+
+	it("returns an empty toolchain without metadata", func() {
+		t, err := rustup.PlanToolchain([]libcnb.BuildpackPlanEntry{
+			{Name: "rust", Metadata: map[string]interface{}{"build": true}},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(t.Name).To(BeEmpty())
+		Expect(t.Profile).To(BeEmpty())
+		Expect(t.Components).To(BeEmpty())
+		Expect(t.Targets).To(BeEmpty())
+	})
+
+	it("merges the requests of all entries", func() {
+		t, err := rustup.PlanToolchain([]libcnb.BuildpackPlanEntry{
+			{Name: "rust", Metadata: map[string]interface{}{
+				"components": []interface{}{"rust-src"},
+				"targets":    "wasm32-unknown-unknown",
+			}},
+			{Name: "cargo", Metadata: map[string]interface{}{"toolchain": "beta"}},
+			{Name: "rust", Metadata: map[string]interface{}{
+				"toolchain":  "nightly",
+				"profile":    "default",
+				"components": []interface{}{"miri", "rust-src"},
+			}},
+			{Name: "rust", Metadata: map[string]interface{}{
+				"toolchain": "nightly",
+				"profile":   "minimal",
+			}},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(t).To(Equal(rustup.Toolchain{
+			Name:       "nightly",
+			Profile:    "default",
+			Components: []string{"rust-src", "miri"},
+			Targets:    []string{"wasm32-unknown-unknown"},
+		}))
+	})
+
+	it("rejects conflicting toolchains", func() {
+		_, err := rustup.PlanToolchain([]libcnb.BuildpackPlanEntry{
+			{Name: "rust", Metadata: map[string]interface{}{"toolchain": "stable"}},
+			{Name: "rust", Metadata: map[string]interface{}{"toolchain": "nightly"}},
+		})
+		Expect(err).To(MatchError("build plan entries request conflicting toolchains stable and nightly"))
+	})
+
+	it("rejects an invalid profile", func() {
+		_, err := rustup.PlanToolchain([]libcnb.BuildpackPlanEntry{
+			{Name: "rust", Metadata: map[string]interface{}{"profile": "huge"}},
+		})
+		Expect(err).To(MatchError(ContainSubstring("invalid profile huge in build plan entry")))
+	})
+
+	it("rejects invalid components", func() {
+		_, err := rustup.PlanToolchain([]libcnb.BuildpackPlanEntry{
+			{Name: "rust", Metadata: map[string]interface{}{"components": []interface{}{"clippy", 1}}},
+		})
+		Expect(err).To(MatchError(ContainSubstring("invalid components in build plan entry")))
+	})
+	context("MergePlanToolchain", func() {
+		var (
+			buf    *bytes.Buffer
+			logger bard.Logger
+		)
+
+		it.Before(func() {
+			buf = &bytes.Buffer{}
+			logger = bard.NewLogger(buf)
+		})
+
+		toolchains := func() []rustup.Toolchain {
+			return []rustup.Toolchain{
+				{Name: "stable", Profile: "minimal"},
+				{Name: "local", Path: "/opt/rust"},
+			}
+		}
+
+		it("leaves the toolchains without a request", func() {
+			Expect(rustup.MergePlanToolchain(logger, toolchains(), rustup.Toolchain{}, "stable", "minimal")).
+				To(Equal(toolchains()))
+		})
+
+		it("merges into the default toolchain", func() {
+			merged := rustup.MergePlanToolchain(logger, toolchains(),
+				rustup.Toolchain{Profile: "default", Components: []string{"rust-src"}}, "stable", "minimal")
+
+			Expect(merged).To(HaveLen(2))
+			Expect(merged[0].Profile).To(Equal("default"))
+			Expect(merged[0].Components).To(Equal([]string{"rust-src"}))
+		})
+
+		it("merges into the requested toolchain", func() {
+			in := append(toolchains(), rustup.Toolchain{Name: "1.78.0", Profile: "minimal"})
+			merged := rustup.MergePlanToolchain(logger, in,
+				rustup.Toolchain{Name: "1.78.0", Range: "1.78.*", Targets: []string{"wasm32-unknown-unknown"}}, "stable", "minimal")
+
+			Expect(merged).To(HaveLen(3))
+			Expect(merged[0].Targets).To(BeEmpty())
+			Expect(merged[2].Targets).To(Equal([]string{"wasm32-unknown-unknown"}))
+			Expect(in[2].Targets).To(BeEmpty())
+		})
+
+		it("does not change a linked toolchain", func() {
+			merged := rustup.MergePlanToolchain(logger, toolchains(),
+				rustup.Toolchain{Components: []string{"rust-src"}}, "local", "minimal")
+
+			Expect(merged).To(Equal(toolchains()))
+			Expect(buf.String()).To(ContainSubstring("Skipping build plan targets and components for linked toolchain local"))
+		})
+
+		it("installs a requested toolchain next to the configured ones", func() {
+			merged := rustup.MergePlanToolchain(logger, toolchains(),
+				rustup.Toolchain{Name: "1.78.1", Range: "1.78.*", Components: []string{"rust-src"}}, "stable", "minimal")
+
+			Expect(merged).To(HaveLen(3))
+			Expect(merged[2]).To(Equal(rustup.Toolchain{
+				Name:       "1.78.1",
+				Range:      "1.78.*",
+				Profile:    "minimal",
+				Components: []string{"rust-src"},
+			}))
+			Expect(buf.String()).To(ContainSubstring("the build plan requests toolchain 1.78.1, but the application configures stable"))
+		})
+	})
+}
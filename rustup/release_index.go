@@ -0,0 +1,231 @@
+/*
+ * Copyright 2018-2024 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rustup
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/paketo-buildpacks/libpak"
+	"github.com/paketo-buildpacks/libpak/bard"
+)
+
+// IsVersionRange returns true if a toolchain is a version range like `1.78.*`, `~1.80` or `>=1.75, <1.82` rather
+// than a channel rustup installs directly
+func IsVersionRange(toolchain string) bool {
+	return strings.ContainsAny(toolchain, "*~^<>=,|") || strings.HasSuffix(toolchain, ".x")
+}
+
+// ReleaseIndexLocation returns the release index configured with $BP_RUST_RELEASE_INDEX, as a URL or a path relative
+// to the application. If that is not set, the index of the mirror is returned. Without a mirror it returns an empty
+// string, the buildpack does not download the index from https://static.rust-lang.org on its own.
+func ReleaseIndexLocation(cr libpak.ConfigurationResolver, mirror Mirror, appPath string) string {
+	if val, _ := cr.Resolve("BP_RUST_RELEASE_INDEX"); val != "" {
+		if u, err := url.Parse(val); err == nil && u.Scheme != "" {
+			return val
+		}
+		if !filepath.IsAbs(val) {
+			val = filepath.Join(appPath, val)
+		}
+		return val
+	}
+
+	if mirror.DistServer != "" {
+		return fmt.Sprintf("%s/manifests.txt", mirror.DistServer)
+	}
+
+	return ""
+}
+
+// ReadReleaseIndex reads the Rust versions of a release index from a path or an http, https or file URL. HTTPS
+// connections trust the given pool of CAs, or the system CAs if it is nil.
+func ReadReleaseIndex(location string, pool *x509.CertPool) ([]*semver.Version, error) {
+	u, err := url.Parse(location)
+	if err != nil || u.Scheme == "" {
+		return readReleaseIndexFile(location)
+	}
+
+	switch u.Scheme {
+	case "file":
+		return readReleaseIndexFile(u.Path)
+	case "http", "https":
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+
+		client := http.Client{Timeout: 30 * time.Second, Transport: transport}
+		resp, err := client.Get(location)
+		if err != nil {
+			return nil, fmt.Errorf("unable to download %s\n%w", redact(location), err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("unable to download %s: %s", redact(location), resp.Status)
+		}
+
+		return ParseReleaseIndex(resp.Body)
+	default:
+		return nil, fmt.Errorf("invalid release index %q, must be a path or an http, https or file URL", redact(location))
+	}
+}
+
+func readReleaseIndexFile(path string) ([]*semver.Version, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open %s\n%w", path, err)
+	}
+	defer in.Close()
+
+	return ParseReleaseIndex(in)
+}
+
+var channelManifestPattern = regexp.MustCompile(`channel-rust-(\d+\.\d+\.\d+)\.toml$`)
+
+// ParseReleaseIndex parses a list of Rust versions, one per line. Lines may also be channel manifest locations like
+// those in https://static.rust-lang.org/manifests.txt, for example
+// `static.rust-lang.org/dist/2024-05-02/channel-rust-1.78.0.toml`. Other lines, like the manifests of beta and nightly
+// channels, are skipped.
+func ParseReleaseIndex(r io.Reader) ([]*semver.Version, error) {
+	seen := map[string]bool{}
+	var versions []*semver.Version
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if m := channelManifestPattern.FindStringSubmatch(line); m != nil {
+			line = m[1]
+		}
+
+		v, err := semver.StrictNewVersion(line)
+		if err != nil || v.Prerelease() != "" || seen[v.String()] {
+			continue
+		}
+
+		seen[v.String()] = true
+		versions = append(versions, v)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read release index\n%w", err)
+	}
+
+	sort.Sort(semver.Collection(versions))
+	return versions, nil
+}
+
+// ResolveVersionRange returns the latest version matching a version range
+func ResolveVersionRange(versionRange string, versions []*semver.Version) (string, error) {
+	c, err := semver.NewConstraint(versionRange)
+	if err != nil {
+		return "", fmt.Errorf("invalid version range %q\n%w", versionRange, err)
+	}
+
+	var latest *semver.Version
+	for _, v := range versions {
+		if c.Check(v) && (latest == nil || v.GreaterThan(latest)) {
+			latest = v
+		}
+	}
+
+	if latest == nil {
+		return "", fmt.Errorf("no Rust release matches %s", versionRange)
+	}
+
+	return latest.String(), nil
+}
+
+// RangeResolver resolves version ranges of toolchains to the latest matching Rust release. The releases are read once,
+// from the packaged toolchains if there are any, and from the release index otherwise. A range locked in
+// rust-toolchain.lock resolves to the locked release without reading either.
+type RangeResolver struct {
+	Logger bard.Logger
+
+	// Index is the location of the release index, empty if there is none
+	Index string
+
+	// Packaged are the toolchains packaged with the buildpack
+	Packaged []libpak.BuildpackDependency
+
+	// Certificates are the CA certificates from bindings to trust when downloading the release index
+	Certificates []CACertificate
+
+	// Lock is the lock file to read locked ranges from, empty unless it is read
+	Lock ToolchainLock
+
+	versions []*semver.Version
+	source   string
+}
+
+// Resolve returns the Rust release a version range resolves to
+func (r *RangeResolver) Resolve(versionRange string) (string, error) {
+	if locked, ok := r.Lock.Find(versionRange); ok {
+		r.Logger.Header("Rust version range")
+		r.Logger.Bodyf("Using %s for %s from %s", locked.Channel, versionRange, LockFileName)
+		return locked.Channel, nil
+	}
+
+	if r.versions == nil {
+		if err := r.readVersions(); err != nil {
+			return "", err
+		}
+	}
+
+	version, err := ResolveVersionRange(versionRange, r.versions)
+	if err != nil {
+		return "", fmt.Errorf("unable to resolve toolchain version range\n%w", err)
+	}
+
+	r.Logger.Header("Rust version range")
+	r.Logger.Bodyf("Resolved %s to %s using %s", versionRange, version, r.source)
+	return version, nil
+}
+
+func (r *RangeResolver) readVersions() error {
+	var err error
+
+	switch {
+	case len(r.Packaged) > 0:
+		r.source = "packaged toolchains"
+		r.versions, err = RustDistVersions(r.Packaged)
+	case r.Index != "":
+		r.source = redact(r.Index)
+
+		var pool *x509.CertPool
+		if pool, err = CertPool(SystemCABundles, r.Certificates); err != nil {
+			return fmt.Errorf("unable to create CA certificate pool\n%w", err)
+		}
+		r.versions, err = ReadReleaseIndex(r.Index, pool)
+	default:
+		return fmt.Errorf("no release index to resolve version ranges, set $BP_RUST_RELEASE_INDEX or configure a mirror")
+	}
+	if err != nil {
+		return fmt.Errorf("unable to read Rust release index\n%w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,197 @@
+/*
+ * Copyright 2018-2024 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rustup_test
+
+import (
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/buildpacks/libcnb"
+	. "github.com/onsi/gomega"
+	"github.com/paketo-buildpacks/libpak"
+	"github.com/paketo-community/rustup/rustup"
+	"github.com/sclevine/spec"
+)
+
+func testReleaseIndex(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		versions []*semver.Version
+	)
+
+	it.Before(func() {
+		var err error
+
+		versions, err = rustup.ReadReleaseIndex(filepath.Join("testdata", "manifests.txt"), nil)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	it("detects version ranges", func() {
+		Expect(rustup.IsVersionRange("1.78.*")).To(BeTrue())
+		Expect(rustup.IsVersionRange("~1.80")).To(BeTrue())
+		Expect(rustup.IsVersionRange(">=1.75, <1.82")).To(BeTrue())
+		Expect(rustup.IsVersionRange("1.78.x")).To(BeTrue())
+		Expect(rustup.IsVersionRange("1.78.0")).To(BeFalse())
+		Expect(rustup.IsVersionRange("1.78")).To(BeFalse())
+		Expect(rustup.IsVersionRange("stable")).To(BeFalse())
+		Expect(rustup.IsVersionRange("nightly-2024-05-01")).To(BeFalse())
+	})
+
+	it("reads the stable releases of manifests.txt", func() {
+		var s []string
+		for _, v := range versions {
+			s = append(s, v.String())
+		}
+
+		Expect(s).To(Equal([]string{"1.77.0", "1.77.1", "1.77.2", "1.78.0", "1.79.0", "1.80.0", "1.80.1", "1.81.0", "1.82.0"}))
+	})
+
+	it("reads a list of versions", func() {
+		versions, err := rustup.ParseReleaseIndex(strings.NewReader("1.80.0\n1.78.0\n\n1.80.0\n"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(versions).To(HaveLen(2))
+		Expect(versions[1].String()).To(Equal("1.80.0"))
+	})
+
+	it("reads an index over http", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/manifests.txt" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			fmt.Fprintln(w, "static.rust-lang.org/dist/2024-05-02/channel-rust-1.78.0.toml")
+		}))
+		defer server.Close()
+
+		versions, err := rustup.ReadReleaseIndex(server.URL+"/manifests.txt", nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(versions).To(HaveLen(1))
+
+		_, err = rustup.ReadReleaseIndex(server.URL+"/missing.txt", nil)
+		Expect(err).To(MatchError(ContainSubstring("404")))
+	})
+
+	it("trusts the CA certificates from bindings over https", func() {
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintln(w, "1.78.0")
+		}))
+		defer server.Close()
+
+		_, err := rustup.ReadReleaseIndex(server.URL+"/manifests.txt", nil)
+		Expect(err).To(MatchError(ContainSubstring("certificate")))
+
+		ca := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+		pool, err := rustup.CertPool(nil, []rustup.CACertificate{{Name: "proxy/ca.pem", PEM: ca}})
+		Expect(err).NotTo(HaveOccurred())
+
+		versions, err := rustup.ReadReleaseIndex(server.URL+"/manifests.txt", pool)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(versions).To(HaveLen(1))
+	})
+
+	context("ResolveVersionRange", func() {
+		it("resolves the latest matching version", func() {
+			Expect(rustup.ResolveVersionRange("1.77.*", versions)).To(Equal("1.77.2"))
+			Expect(rustup.ResolveVersionRange("~1.80", versions)).To(Equal("1.80.1"))
+			Expect(rustup.ResolveVersionRange(">=1.75, <1.82", versions)).To(Equal("1.81.0"))
+		})
+
+		it("fails without a matching version", func() {
+			_, err := rustup.ResolveVersionRange("1.90.*", versions)
+			Expect(err).To(MatchError("no Rust release matches 1.90.*"))
+		})
+
+		it("fails on an invalid range", func() {
+			_, err := rustup.ResolveVersionRange(">=stable", versions)
+			Expect(err).To(MatchError(ContainSubstring("invalid version range")))
+		})
+	})
+
+	context("ReleaseIndexLocation", func() {
+		var cr libpak.ConfigurationResolver
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_RUST_RELEASE_INDEX")).To(Succeed())
+		})
+
+		resolver := func() libpak.ConfigurationResolver {
+			cr, err := libpak.NewConfigurationResolver(libcnb.Buildpack{}, nil)
+			Expect(err).NotTo(HaveOccurred())
+			return cr
+		}
+
+		it("has no default index", func() {
+			cr = resolver()
+			Expect(rustup.ReleaseIndexLocation(cr, rustup.Mirror{}, "/workspace")).To(BeEmpty())
+		})
+
+		it("uses the mirror", func() {
+			cr = resolver()
+			Expect(rustup.ReleaseIndexLocation(cr, rustup.Mirror{DistServer: "https://mirror.example.com/rust"}, "/workspace")).
+				To(Equal("https://mirror.example.com/rust/manifests.txt"))
+		})
+
+		it("resolves a path against the application", func() {
+			Expect(os.Setenv("BP_RUST_RELEASE_INDEX", "ci/manifests.txt")).To(Succeed())
+			cr = resolver()
+			Expect(rustup.ReleaseIndexLocation(cr, rustup.Mirror{}, "/workspace")).To(Equal("/workspace/ci/manifests.txt"))
+		})
+
+		it("keeps a URL", func() {
+			Expect(os.Setenv("BP_RUST_RELEASE_INDEX", "file:///index/manifests.txt")).To(Succeed())
+			cr = resolver()
+			Expect(rustup.ReleaseIndexLocation(cr, rustup.Mirror{DistServer: "https://mirror.example.com/rust"}, "/workspace")).
+				To(Equal("file:///index/manifests.txt"))
+		})
+	})
+	context("RangeResolver", func() {
+		it("resolves ranges against the release index", func() {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				fmt.Fprintln(w, "1.77.2\n1.78.0\n1.78.1")
+			}))
+			defer server.Close()
+
+			r := &rustup.RangeResolver{Index: server.URL + "/manifests.txt"}
+			Expect(r.Resolve("1.78.*")).To(Equal("1.78.1"))
+			Expect(r.Resolve("1.77.*")).To(Equal("1.77.2"))
+			Expect(requests).To(Equal(1))
+		})
+
+		it("uses the locked release of a range", func() {
+			r := &rustup.RangeResolver{
+				Lock: rustup.ToolchainLock{Toolchains: []rustup.LockedToolchain{{Name: "1.78.*", Channel: "1.78.0"}}},
+			}
+			Expect(r.Resolve("1.78.*")).To(Equal("1.78.0"))
+		})
+
+		it("fails without a release index", func() {
+			r := &rustup.RangeResolver{}
+			_, err := r.Resolve("1.78.*")
+			Expect(err).To(MatchError(ContainSubstring("no release index")))
+		})
+	})
+}
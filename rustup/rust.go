@@ -22,75 +22,89 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/buildpacks/libcnb"
+	"github.com/heroku/color"
 	"github.com/paketo-buildpacks/libpak"
 	"github.com/paketo-buildpacks/libpak/bard"
 	"github.com/paketo-buildpacks/libpak/effect"
 	"github.com/paketo-buildpacks/libpak/sbom"
-	"github.com/paketo-buildpacks/libpak/sherpa"
 )
 
-// Rust will run `rustup` from the PATH to install a given toolchain
+// Rust will run `rustup` from the PATH to install the given toolchains
 type Rust struct {
 	LayerContributor libpak.LayerContributor
 	Logger           bard.Logger
 	Arguments        []string
 	Executor         effect.Executor
-	Toolchain        string
-	ToolchainSet     bool
-	Target           string
-	Profile          string
-	ProfileSet       bool
-	ToolchainFile    string
+	Toolchains       []Toolchain
+	DefaultToolchain string
+	UpdatePolicy     UpdatePolicy
+	LockMode         string
+	LockPath         string
+	Lock             ToolchainLock
+	Mirror           Mirror
+	Advisories       []Advisory
+	AdvisoryFail     bool
+	Requirements     []RustVersionRequirement
+	RequirementsFail bool
 }
 
-func NewRust(profile, toolchain, target, toolchainFile string, profileSet, toolchainSet bool) Rust {
+func NewRust(toolchains []Toolchain, defaultToolchain string) Rust {
+	var metadata []map[string]interface{}
+	for _, t := range toolchains {
+		metadata = append(metadata, t.Metadata())
+	}
+
 	return Rust{
 		LayerContributor: libpak.NewLayerContributor(
 			"Rust",
 			map[string]interface{}{
-				"toolchain": toolchain,
-				"profile":   profile,
-				"target":    target,
+				"toolchains": metadata,
+				"default":    defaultToolchain,
 			},
 			libcnb.LayerTypes{
 				Build: true,
 				Cache: true,
 			}),
-		Executor:      effect.NewExecutor(),
-		Profile:       profile,
-		ProfileSet:    profileSet,
-		Target:        target,
-		Toolchain:     toolchain,
-		ToolchainSet:  toolchainSet,
-		ToolchainFile: toolchainFile,
+		Executor:         effect.NewExecutor(),
+		Toolchains:       toolchains,
+		DefaultToolchain: defaultToolchain,
+		UpdatePolicy:     UpdatePolicy{Mode: UpdatePolicyAlways},
+		LockMode:         LockModeOff,
 	}
 }
 
 func (r Rust) Contribute(layer libcnb.Layer) (libcnb.Layer, error) {
 	r.LayerContributor.Logger = r.Logger
 
-	// add `rustup check` to expected metadata if upstream rust changes, it won't match the layer metadata
-	buf := bytes.Buffer{}
-	if err := r.Executor.Execute(effect.Execution{
-		Command: "rustup",
-		Args:    []string{"check"},
-		Stdout:  &buf,
-		Stderr:  &buf,
-	}); err != nil {
-		return libcnb.Layer{}, fmt.Errorf("unable to run `rustup check`: %s\n%w", buf.String(), err)
+	// add the manifests of installed toolchains to expected metadata, if the toolchains are removed or changed outside
+	// of this layer, it won't match the layer metadata
+	manifests, err := r.installedManifests()
+	if err != nil {
+		return libcnb.Layer{}, fmt.Errorf("unable to read installed toolchain manifests\n%w", err)
 	}
-	r.LayerContributor.ExpectedMetadata.(map[string]interface{})["installed"] = strings.TrimSpace(buf.String())
+	r.LayerContributor.ExpectedMetadata.(map[string]interface{})["manifests"] = manifests
 
-	// add hash of rust toolchain file (rust-toolchain.toml or rust-toolchain) so it re-runs if that file changes
-	if hash, err := sherpa.NewFileListingHash(r.ToolchainFile); err != nil {
-		return libcnb.Layer{}, fmt.Errorf("unable to hash: %s\n%w", r.ToolchainFile, err)
-	} else {
-		r.LayerContributor.ExpectedMetadata.(map[string]interface{})["rust-toolchain"] = hash
+	// toolchains downloaded from a different mirror may differ, so switching mirrors reinstalls them
+	if r.Mirror.IsSet() {
+		r.LayerContributor.ExpectedMetadata.(map[string]interface{})["mirror"] = r.Mirror.Metadata()
 	}
 
-	layer, err := r.LayerContributor.Contribute(layer, func() (libcnb.Layer, error) {
+	switch r.UpdatePolicy.Mode {
+	case UpdatePolicyAlways:
+		// add `rustup check` to expected metadata if upstream rust changes, it won't match the layer metadata
+		if installed, ok := r.checkForUpdates(layer.Metadata); ok {
+			r.LayerContributor.ExpectedMetadata.(map[string]interface{})["installed"] = installed
+		}
+	case UpdatePolicyInterval:
+		// add the install time to expected metadata, once it is older than the interval it won't match the layer metadata
+		r.LayerContributor.ExpectedMetadata.(map[string]interface{})["installed-at"] =
+			r.UpdatePolicy.InstalledAt(layer.Metadata["installed-at"], time.Now())
+	}
+
+	layer, err = r.LayerContributor.Contribute(layer, func() (libcnb.Layer, error) {
 		r.Logger.Body("Installing Rust")
 
 		// This layer doesn't actually contain files, they write to RUSTUP_HOME, because Rustup is installing them.
@@ -110,56 +124,65 @@ func (r Rust) Contribute(layer libcnb.Layer) (libcnb.Layer, error) {
 			}
 		}
 
-		rustToolChainFileExists := false
-		if _, err := os.Stat(r.ToolchainFile); err == nil {
-			rustToolChainFileExists = true
-		}
-
-		if rustToolChainFileExists {
-			if err := r.installFromRustToolChainFile(layer); err != nil {
-				return libcnb.Layer{}, fmt.Errorf("unable to install rust from toolchain file\n%w", err)
-			}
-		}
-
-		if !rustToolChainFileExists || r.ProfileSet || r.ToolchainSet {
-			if err := r.installRust(layer); err != nil {
-				return libcnb.Layer{}, fmt.Errorf("unable to install rust\n%w", err)
+		for _, toolchain := range r.Toolchains {
+			if err := r.installToolchain(layer, toolchain); err != nil {
+				return libcnb.Layer{}, fmt.Errorf("unable to install rust toolchain %s\n%w", toolchain.Name, err)
 			}
 		}
 
-		if err := r.installAdditionalTarget(layer); err != nil {
-			return libcnb.Layer{}, fmt.Errorf("unable to install additional rust target\n%w", err)
+		if err := r.setDefaultToolchain(layer); err != nil {
+			return libcnb.Layer{}, fmt.Errorf("unable to set default rust toolchain\n%w", err)
 		}
 
 		buf := &bytes.Buffer{}
 		if err := r.Executor.Execute(effect.Execution{
 			Command: "rustc",
-			Args:    []string{"--version"},
+			Args:    []string{"-vV"},
 			Stdout:  buf,
 			Stderr:  buf,
 		}); err != nil {
-			return libcnb.Layer{}, fmt.Errorf("error executing 'rustc --version':\n Combined Output: %s: \n%w", buf.String(), err)
+			return libcnb.Layer{}, fmt.Errorf("error executing 'rustc -vV':\n Combined Output: %s: \n%w", buf.String(), err)
 		}
-		ver := strings.Split(strings.TrimSpace(buf.String()), " ")
 
-		sbomPath := layer.SBOMPath(libcnb.SyftJSON)
-		dep := sbom.NewSyftDependency(layer.Path, []sbom.SyftArtifact{
+		ver, err := ParseRustcVersion(buf.String())
+		if err != nil {
+			return libcnb.Layer{}, fmt.Errorf("unable to parse rustc version\n%w", err)
+		}
+		r.Logger.Bodyf("Installed rustc %s (%s) for %s", ver.Release, ver.CommitHash, ver.Host)
+
+		artifacts := []SBOMArtifact{
 			{
-				ID:      "rust",
-				Name:    "Rust",
-				Version: ver[1],
-				Type:    "UnknownPackage",
-				FoundBy: "paketo-community/rustup",
-				Locations: []sbom.SyftLocation{
-					{Path: "paketo-community/rustup/rustup/rust.go"},
+				SyftArtifact: sbom.SyftArtifact{
+					ID:      "rust",
+					Name:    "Rust",
+					Version: ver.Release,
+					Type:    "binary",
+					FoundBy: "paketo-community/rustup",
+					Locations: []sbom.SyftLocation{
+						{Path: "paketo-community/rustup/rustup/rust.go"},
+					},
+					Licenses: []string{"Apache-2.0", "MIT"},
+					CPEs:     []string{fmt.Sprintf("cpe:2.3:a:rust-lang:rust:%s:*:*:*:*:*:*:*", ver.Release)},
+					PURL: fmt.Sprintf("pkg:github/rust-lang/rust@%s%s", ver.Release,
+						purlQualifiers(map[string]string{"commit": ver.CommitHash})),
+				},
+				Properties: map[string]string{
+					"rust:commit-hash":  ver.CommitHash,
+					"rust:commit-date":  ver.CommitDate,
+					"rust:host":         ver.Host,
+					"rust:llvm-version": ver.LLVMVersion,
 				},
-				Licenses: []string{"Apache-2.0", "MIT"},
-				CPEs:     []string{fmt.Sprintf("cpe:2.3:a:rust:rust:%s:*:*:*:*:*:*:*", ver[1])},
-				PURL:     fmt.Sprintf("pkg:generic/rust@%s", ver[1]),
 			},
-		})
-		r.Logger.Debugf("Writing Syft SBOM at %s: %+v", sbomPath, dep)
-		if err := dep.WriteTo(sbomPath); err != nil {
+		}
+
+		components, err := r.componentArtifacts()
+		if err != nil {
+			return libcnb.Layer{}, fmt.Errorf("unable to read installed components\n%w", err)
+		}
+
+		artifacts = append(artifacts, components...)
+
+		if err := WriteSBOM(layer, r.Logger, artifacts); err != nil {
 			return libcnb.Layer{}, fmt.Errorf("unable to write SBOM\n%w", err)
 		}
 
@@ -170,21 +193,43 @@ func (r Rust) Contribute(layer libcnb.Layer) (libcnb.Layer, error) {
 	}
 
 	// update metadata
-	buf = bytes.Buffer{}
-	if err := r.Executor.Execute(effect.Execution{
-		Command: "rustup",
-		Args:    []string{"check"},
-		Stdout:  &buf,
-		Stderr:  &buf,
-	}); err != nil {
-		return libcnb.Layer{}, fmt.Errorf("unable to run `rustup check`: %s\n%w", buf.String(), err)
+	manifests, err = r.installedManifests()
+	if err != nil {
+		return libcnb.Layer{}, fmt.Errorf("unable to read installed toolchain manifests\n%w", err)
+	}
+	layer.Metadata["manifests"] = manifests
+
+	if r.UpdatePolicy.Mode == UpdatePolicyAlways {
+		if installed, ok := r.checkForUpdates(layer.Metadata); ok {
+			layer.Metadata["installed"] = installed
+		}
+	}
+
+	if err := r.writeToolchainInfo(&layer); err != nil {
+		return libcnb.Layer{}, fmt.Errorf("unable to write toolchain info\n%w", err)
+	}
+
+	switch r.LockMode {
+	case LockModeRead:
+		if err := r.verifyLock(); err != nil {
+			return libcnb.Layer{}, fmt.Errorf("unable to verify toolchain lock\n%w", err)
+		}
+	case LockModeWrite:
+		if err := r.writeLock(); err != nil {
+			return libcnb.Layer{}, fmt.Errorf("unable to write toolchain lock\n%w", err)
+		}
 	}
-	layer.Metadata["installed"] = strings.TrimSpace(buf.String())
 
-	if hash, err := sherpa.NewFileListingHash(r.ToolchainFile); err != nil {
-		return libcnb.Layer{}, fmt.Errorf("unable to hash: %s\n%w", r.ToolchainFile, err)
-	} else {
-		layer.Metadata["rust-toolchain"] = hash
+	if len(r.Requirements) > 0 {
+		if err := r.checkRequirements(); err != nil {
+			return libcnb.Layer{}, err
+		}
+	}
+
+	if len(r.Advisories) > 0 {
+		if err := r.scanAdvisories(); err != nil {
+			return libcnb.Layer{}, err
+		}
 	}
 
 	return layer, nil
@@ -194,77 +239,467 @@ func (r Rust) Name() string {
 	return r.LayerContributor.Name
 }
 
-func (r Rust) installRust(layer libcnb.Layer) error {
+// checkForUpdates runs `rustup check`, which requires network access. If it fails, the result recorded in the layer
+// metadata is returned so that a cached toolchain is still used.
+func (r Rust) checkForUpdates(metadata map[string]interface{}) (interface{}, bool) {
+	buf := bytes.Buffer{}
 	if err := r.Executor.Execute(effect.Execution{
 		Command: "rustup",
-		Args: []string{
-			"-q",
-			"toolchain",
-			"install",
-			fmt.Sprintf("--profile=%s", r.Profile),
-			r.Toolchain,
-		},
-		Dir:    layer.Path,
-		Stdout: bard.NewWriter(r.Logger.Logger.InfoWriter(), bard.WithIndent(3)),
-		Stderr: bard.NewWriter(r.Logger.Logger.InfoWriter(), bard.WithIndent(3)),
+		Args:    []string{"check"},
+		Stdout:  &buf,
+		Stderr:  &buf,
 	}); err != nil {
-		return fmt.Errorf("unable to run `rustup toolchain install`\n%w", err)
+		r.Logger.Bodyf("%s unable to run `rustup check`, skipping update check: %s",
+			color.YellowString("Warning:"), strings.TrimSpace(buf.String()))
+		previous, ok := metadata["installed"]
+		return previous, ok
+	}
+
+	return strings.TrimSpace(buf.String()), true
+}
+
+// installedManifests returns the resolved channel, date and hash of the channel manifest of each installed toolchain
+func (r Rust) installedManifests() (map[string]interface{}, error) {
+	resolved, err := r.resolvedToolchains()
+	if err != nil {
+		return nil, err
+	}
+
+	manifests := map[string]interface{}{}
+	for _, t := range resolved {
+		manifests[t.Name] = map[string]interface{}{
+			"channel": t.Channel,
+			"date":    t.Date,
+			"hash":    t.Hash,
+		}
+	}
+
+	return manifests, nil
+}
+
+// resolvedToolchains returns the exact release of each installed toolchain, linked toolchains are skipped as they have
+// no channel manifest
+func (r Rust) resolvedToolchains() ([]LockedToolchain, error) {
+	rustupHome, ok := os.LookupEnv("RUSTUP_HOME")
+	if !ok {
+		return nil, nil
+	}
+
+	var resolved []LockedToolchain
+	for _, t := range r.Toolchains {
+		m, ok, err := InstalledManifest(rustupHome, t.Channel())
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+
+		resolved = append(resolved, LockedToolchain{
+			Name:    t.LockName(),
+			Channel: m.ResolvedChannel(t.Channel()),
+			Date:    m.Date,
+			Hash:    m.Hash,
+		})
+	}
+
+	return resolved, nil
+}
+
+// componentArtifacts returns an SBOM artifact for each component of each installed toolchain, with the version and
+// hash from the channel manifest the component was installed from
+func (r Rust) componentArtifacts() ([]SBOMArtifact, error) {
+	rustupHome, ok := os.LookupEnv("RUSTUP_HOME")
+	if !ok {
+		return nil, nil
+	}
+
+	var artifacts []SBOMArtifact
+	for _, t := range r.Toolchains {
+		if t.IsLinked() {
+			continue
+		}
+
+		dir, ok, err := ToolchainDirectory(rustupHome, t.Channel())
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+
+		m, ok, err := InstalledManifest(rustupHome, t.Channel())
+		if err != nil {
+			return nil, err
+		}
+
+		var (
+			components []InstalledComponent
+			unknown    []string
+		)
+		if ok {
+			if components, unknown, err = InstalledComponents(dir, m); err != nil {
+				return nil, err
+			}
+		}
+
+		// toolchains installed by older rustup versions record neither their manifest nor their components
+		if len(components) == 0 && len(unknown) == 0 {
+			r.Logger.Bodyf("%s the component inventory of toolchain %s is unavailable, its components are missing from the SBOM",
+				color.YellowString("Warning:"), t.Name)
+			continue
+		}
+
+		for _, name := range unknown {
+			r.Logger.Bodyf("%s component %s of toolchain %s is not in its channel manifest, it is missing from the SBOM",
+				color.YellowString("Warning:"), name, t.Name)
+		}
+
+		for _, c := range components {
+			id := fmt.Sprintf("rust-%s-%s", t.Name, c.Name)
+			if c.Target != "" {
+				id = fmt.Sprintf("%s-%s", id, c.Target)
+			}
+			qualifiers := map[string]string{
+				"checksum": fmt.Sprintf("sha256:%s", c.SHA256),
+				"target":   c.Target,
+			}
+
+			artifacts = append(artifacts, SBOMArtifact{
+				SyftArtifact: sbom.SyftArtifact{
+					ID:      id,
+					Name:    c.Name,
+					Version: c.Version,
+					Type:    "binary",
+					FoundBy: "paketo-community/rustup",
+					Locations: []sbom.SyftLocation{
+						{Path: "paketo-community/rustup/rustup/rust.go"},
+					},
+					Licenses: []string{"Apache-2.0", "MIT"},
+					PURL:     fmt.Sprintf("pkg:generic/%s@%s%s", c.Name, c.Version, purlQualifiers(qualifiers)),
+				},
+				SHA256: c.SHA256,
+			})
+		}
+	}
+
+	return artifacts, nil
+}
+
+// writeToolchainInfo writes the toolchain descriptor to the layer and points $BP_RUST_TOOLCHAIN_INFO at it. It is
+// written on every build, so it describes the installed toolchains even when the layer is restored from cache.
+func (r Rust) writeToolchainInfo(layer *libcnb.Layer) error {
+	info, err := r.toolchainInfo()
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(layer.Path, ToolchainInfoFile)
+	if err := WriteToolchainInfo(path, info); err != nil {
+		return err
+	}
+
+	layer.BuildEnvironment.Override(ToolchainInfoEnv, path)
+	if err := os.Setenv(ToolchainInfoEnv, path); err != nil {
+		return fmt.Errorf("unable to set $%s\n%w", ToolchainInfoEnv, err)
 	}
 
 	return nil
 }
 
-func (r Rust) installAdditionalTarget(layer libcnb.Layer) error {
-	if r.Target != "" {
+// toolchainInfo describes the installed toolchains, the host triple is that of the default toolchain
+func (r Rust) toolchainInfo() (ToolchainInfo, error) {
+	info := ToolchainInfo{
+		DefaultToolchain: r.DefaultToolchain,
+		RustupHome:       os.Getenv("RUSTUP_HOME"),
+		CargoHome:        os.Getenv("CARGO_HOME"),
+		Toolchains:       []InstalledToolchain{},
+	}
+
+	for _, t := range r.Toolchains {
+		installed, host, err := installedToolchain(info.RustupHome, t)
+		if err != nil {
+			return ToolchainInfo{}, err
+		}
+
+		if t.Name == r.DefaultToolchain {
+			info.Host = host
+		}
+		info.Toolchains = append(info.Toolchains, installed)
+	}
+
+	return info, nil
+}
+
+// installedToolchain describes a toolchain from its channel manifest and `lib/rustlib/components`, and returns its
+// host triple. Toolchains without a components file, like linked toolchains, list the requested targets and components.
+func installedToolchain(rustupHome string, t Toolchain) (InstalledToolchain, string, error) {
+	installed := InstalledToolchain{
+		Name:       t.Name,
+		Path:       t.Path,
+		Linked:     t.IsLinked(),
+		Targets:    sorted(t.Targets),
+		Components: sorted(t.Components),
+	}
+
+	if t.IsLinked() || rustupHome == "" {
+		return installed, "", nil
+	}
+
+	dir, ok, err := ToolchainDirectory(rustupHome, t.Channel())
+	if err != nil || !ok {
+		return installed, "", err
+	}
+	installed.Path = dir
+
+	// rustup appends the host triple to the channel name
+	host := strings.TrimPrefix(filepath.Base(dir), t.Channel()+"-")
+
+	m, ok, err := InstalledManifest(rustupHome, t.Channel())
+	if err != nil || !ok {
+		return installed, host, err
+	}
+	installed.Channel = m.ResolvedChannel(t.Channel())
+	installed.Version = m.RustVersion()
+
+	components, _, err := InstalledComponents(dir, m)
+	if err != nil || len(components) == 0 {
+		return installed, host, err
+	}
+
+	var targets, names []string
+	for _, c := range components {
+		if c.Name == "rustc" && c.Target != "" {
+			host = c.Target
+		}
+		if c.Name == "rust-std" && c.Target != "" && !contains(targets, c.Target) {
+			targets = append(targets, c.Target)
+		}
+		if !contains(names, c.Name) {
+			names = append(names, c.Name)
+		}
+	}
+	installed.Targets, installed.Components = sorted(targets), sorted(names)
+
+	return installed, host, nil
+}
+
+// checkRequirements compares the Rust version of the default toolchain, which builds the application, with the minimum
+// Rust versions. Older versions are logged as warnings, and fail the build if RequirementsFail is set.
+func (r Rust) checkRequirements() error {
+	rustupHome, ok := os.LookupEnv("RUSTUP_HOME")
+	if !ok {
+		return nil
+	}
+
+	t := r.defaultToolchain()
+	m, ok, err := InstalledManifest(rustupHome, t.Channel())
+	if err != nil {
+		return fmt.Errorf("unable to read channel manifest of %s\n%w", t.Name, err)
+	}
+	if !ok || m.RustVersion() == "" {
+		r.Logger.Bodyf("Skipping minimum Rust version check for toolchain %s without a channel manifest", t.Name)
+		return nil
+	}
+
+	var unsatisfied []string
+	for _, req := range r.Requirements {
+		ok, err := req.Satisfied(m.RustVersion())
+		if err != nil {
+			return fmt.Errorf("unable to check %s\n%w", req, err)
+		}
+		if ok {
+			continue
+		}
+
+		r.Logger.Bodyf("%s toolchain %s (rustc %s) is older than %s",
+			color.YellowString("Warning:"), t.Name, m.RustVersion(), req)
+		unsatisfied = append(unsatisfied, req.String())
+	}
+
+	if len(unsatisfied) > 0 && r.RequirementsFail {
+		return fmt.Errorf("toolchain %s (rustc %s) is older than %s, set BP_RUST_VERSION_STRICT=false to continue",
+			t.Name, m.RustVersion(), strings.Join(unsatisfied, " and "))
+	}
+
+	return nil
+}
+
+// scanAdvisories compares the Rust version of each installed toolchain with the advisories. Matches are logged as
+// warnings, and fail the build if AdvisoryFail is set.
+func (r Rust) scanAdvisories() error {
+	rustupHome, ok := os.LookupEnv("RUSTUP_HOME")
+	if !ok {
+		return nil
+	}
+
+	var affected []string
+	for _, t := range r.Toolchains {
+		m, ok, err := InstalledManifest(rustupHome, t.Channel())
+		if err != nil {
+			return fmt.Errorf("unable to read channel manifest of %s\n%w", t.Name, err)
+		}
+		if !ok || m.RustVersion() == "" {
+			continue
+		}
+
+		for _, a := range r.Advisories {
+			ok, err := a.Affects(m.RustVersion())
+			if err != nil {
+				return fmt.Errorf("unable to check advisory %s\n%w", a.ID, err)
+			}
+			if !ok {
+				continue
+			}
+
+			r.Logger.Bodyf("%s toolchain %s (rustc %s) is affected by %s",
+				color.YellowString("Warning:"), t.Name, m.RustVersion(), a)
+			affected = append(affected, fmt.Sprintf("%s (%s)", t.Name, a.ID))
+		}
+	}
+
+	if len(affected) == 0 {
+		r.Logger.Bodyf("No known advisories affect the installed toolchains, checked %d advisories", len(r.Advisories))
+		return nil
+	}
+
+	if r.AdvisoryFail {
+		return fmt.Errorf("toolchains are affected by advisories: %s, set BP_RUST_ADVISORY_FAIL=false to continue",
+			strings.Join(affected, ", "))
+	}
+
+	return nil
+}
+
+// verifyLock checks that the installed toolchains have the channel manifests recorded in the lock file
+func (r Rust) verifyLock() error {
+	resolved, err := r.resolvedToolchains()
+	if err != nil {
+		return err
+	}
+
+	for _, locked := range r.Lock.Toolchains {
+		var found bool
+		for _, t := range resolved {
+			if t.Name != locked.Name {
+				continue
+			}
+
+			found = true
+			if t.Hash != locked.Hash {
+				return fmt.Errorf("toolchain %s (%s) has channel manifest hash %s, but %s expects %s",
+					t.Name, t.Channel, t.Hash, LockFileName, locked.Hash)
+			}
+		}
+
+		if !found && r.isRequested(locked.Name) {
+			return fmt.Errorf("toolchain %s from %s has no channel manifest to verify", locked.Name, LockFileName)
+		}
+	}
+
+	r.Logger.Bodyf("Verified toolchains against %s", LockFileName)
+	return nil
+}
+
+// writeLock writes the exact release of each installed toolchain to the lock file
+func (r Rust) writeLock() error {
+	resolved, err := r.resolvedToolchains()
+	if err != nil {
+		return err
+	}
+
+	r.Logger.Bodyf("Writing %s", r.LockPath)
+	return ToolchainLock{Toolchains: resolved}.Write(r.LockPath)
+}
+
+func (r Rust) isRequested(name string) bool {
+	for _, t := range r.Toolchains {
+		if t.LockName() == name && !t.IsLinked() {
+			return true
+		}
+	}
+	return false
+}
+
+func (r Rust) installToolchain(layer libcnb.Layer, toolchain Toolchain) error {
+	r.Logger.Bodyf("Installing toolchain %s", toolchain)
+
+	if toolchain.IsLinked() {
 		if err := r.Executor.Execute(effect.Execution{
 			Command: "rustup",
 			Args: []string{
-				"-q",
-				"target",
-				"add",
-				fmt.Sprintf("--toolchain=%s", r.Toolchain),
-				r.Target,
+				"toolchain",
+				"link",
+				toolchain.Name,
+				toolchain.Path,
 			},
 			Dir:    layer.Path,
 			Stdout: bard.NewWriter(r.Logger.Logger.InfoWriter(), bard.WithIndent(3)),
 			Stderr: bard.NewWriter(r.Logger.Logger.InfoWriter(), bard.WithIndent(3)),
 		}); err != nil {
-			return fmt.Errorf("unable to run `rustup target add`\n%w", err)
+			return fmt.Errorf("unable to run `rustup toolchain link`\n%w", err)
 		}
+
+		return nil
 	}
 
-	return nil
-}
+	args := []string{
+		"-q",
+		"toolchain",
+		"install",
+		fmt.Sprintf("--profile=%s", toolchain.Profile),
+	}
+	if len(toolchain.Components) > 0 {
+		args = append(args, fmt.Sprintf("--component=%s", strings.Join(toolchain.Components, ",")))
+	}
+	if len(toolchain.Targets) > 0 {
+		args = append(args, fmt.Sprintf("--target=%s", strings.Join(toolchain.Targets, ",")))
+	}
+	args = append(args, toolchain.Channel())
 
-func (r Rust) installFromRustToolChainFile(layer libcnb.Layer) error {
 	if err := r.Executor.Execute(effect.Execution{
 		Command: "rustup",
-		Args: []string{
-			"-q",
-			"default",
-			r.Toolchain,
-		},
-		Dir:    layer.Path,
-		Stdout: bard.NewWriter(r.Logger.Logger.InfoWriter(), bard.WithIndent(3)),
-		Stderr: bard.NewWriter(r.Logger.Logger.InfoWriter(), bard.WithIndent(3)),
+		Args:    args,
+		Dir:     layer.Path,
+		Stdout:  bard.NewWriter(r.Logger.Logger.InfoWriter(), bard.WithIndent(3)),
+		Stderr:  bard.NewWriter(r.Logger.Logger.InfoWriter(), bard.WithIndent(3)),
 	}); err != nil {
-		return fmt.Errorf("unable to run `rustup default`\n%w", err)
+		if len(toolchain.Components) > 0 {
+			return fmt.Errorf("unable to run `rustup toolchain install`, one of %s may not be available for toolchain %s. "+
+				"See https://rust-lang.github.io/rustup-components-history/ for nightly component availability\n%w",
+				strings.Join(toolchain.Components, ", "), toolchain.Name, err)
+		}
+		return fmt.Errorf("unable to run `rustup toolchain install`\n%w", err)
 	}
 
-	// This seems weird, but `rustup show` will actually read rust-toolchain.toml or rust-toolchain
-	// and install anything missing.
+	return nil
+}
+
+func (r Rust) setDefaultToolchain(layer libcnb.Layer) error {
+	r.Logger.Bodyf("Setting default toolchain to %s", r.defaultToolchain().Channel())
+
 	if err := r.Executor.Execute(effect.Execution{
 		Command: "rustup",
 		Args: []string{
 			"-q",
-			"show",
+			"default",
+			r.defaultToolchain().Channel(),
 		},
 		Dir:    layer.Path,
 		Stdout: bard.NewWriter(r.Logger.Logger.InfoWriter(), bard.WithIndent(3)),
 		Stderr: bard.NewWriter(r.Logger.Logger.InfoWriter(), bard.WithIndent(3)),
 	}); err != nil {
-		return fmt.Errorf("unable to run `rustup show`\n%w", err)
+		return fmt.Errorf("unable to run `rustup default`\n%w", err)
 	}
 
 	return nil
 }
+
+func (r Rust) defaultToolchain() Toolchain {
+	for _, t := range r.Toolchains {
+		if t.Name == r.DefaultToolchain {
+			return t
+		}
+	}
+	return Toolchain{Name: r.DefaultToolchain}
+}
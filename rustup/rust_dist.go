@@ -0,0 +1,261 @@
+/*
+ * Copyright 2018-2024 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rustup
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/buildpacks/libcnb"
+	"github.com/paketo-buildpacks/libpak"
+	"github.com/paketo-buildpacks/libpak/bard"
+	"github.com/paketo-buildpacks/libpak/sherpa"
+)
+
+const (
+	// RustDistPrefix is the prefix of the ids of dependencies served to rustup from the packaged dist layout
+	RustDistPrefix = "rust-dist-"
+
+	// RustDistManifestID is the id of channel manifest dependencies, like `channel-rust-1.78.0.toml`
+	RustDistManifestID = "rust-dist-manifest"
+)
+
+// RustDist lays out packaged channel manifests and component archives like https://static.rust-lang.org, so that rustup
+// can install toolchains from a `file://` dist server without network access
+type RustDist struct {
+	LayerContributor libpak.LayerContributor
+	Logger           bard.Logger
+	Dependencies     []libpak.BuildpackDependency
+	DependencyCache  libpak.DependencyCache
+}
+
+func NewRustDist(dependencies []libpak.BuildpackDependency, cache libpak.DependencyCache) RustDist {
+	var metadata []map[string]interface{}
+	for _, d := range dependencies {
+		metadata = append(metadata, map[string]interface{}{
+			"id":      d.ID,
+			"version": d.Version,
+			"sha256":  d.SHA256,
+		})
+	}
+
+	return RustDist{
+		LayerContributor: libpak.NewLayerContributor(
+			"rust-dist",
+			map[string]interface{}{
+				"dependencies": metadata,
+			},
+			libcnb.LayerTypes{
+				Cache: true,
+			}),
+		Dependencies:    dependencies,
+		DependencyCache: cache,
+	}
+}
+
+// ResolveRustDistDependencies returns the `rust-dist-*` dependencies for the current stack and architecture. It
+// returns nothing unless at least one channel manifest is packaged.
+func ResolveRustDistDependencies(dr libpak.DependencyResolver) ([]libpak.BuildpackDependency, error) {
+	// the resolver returns only the latest version of an id, so each version is resolved on its own
+	var keys [][2]string
+	for _, d := range dr.Dependencies {
+		k := [2]string{d.ID, d.Version}
+		if strings.HasPrefix(d.ID, RustDistPrefix) && !containsPair(keys, k) {
+			keys = append(keys, k)
+		}
+	}
+
+	var (
+		dependencies []libpak.BuildpackDependency
+		manifests    bool
+	)
+	for _, k := range keys {
+		d, err := dr.Resolve(k[0], k[1])
+		if errors.As(err, &libpak.NoValidDependenciesError{}) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("unable to resolve %s %s\n%w", k[0], k[1], err)
+		}
+
+		dependencies = append(dependencies, d)
+		manifests = manifests || d.ID == RustDistManifestID
+	}
+
+	if !manifests {
+		return nil, nil
+	}
+
+	return dependencies, nil
+}
+
+// RustDistVersions returns the Rust versions of the packaged channel manifests, sorted from oldest to latest
+func RustDistVersions(dependencies []libpak.BuildpackDependency) ([]*semver.Version, error) {
+	var versions []*semver.Version
+	for _, d := range dependencies {
+		if d.ID != RustDistManifestID {
+			continue
+		}
+
+		v, err := semver.NewVersion(d.Version)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse version %s of %s\n%w", d.Version, d.ID, err)
+		}
+		versions = append(versions, v)
+	}
+	sort.Sort(semver.Collection(versions))
+
+	return versions, nil
+}
+
+func (r RustDist) Contribute(layer libcnb.Layer) (libcnb.Layer, error) {
+	r.LayerContributor.Logger = r.Logger
+
+	return r.LayerContributor.Contribute(layer, func() (libcnb.Layer, error) {
+		r.Logger.Body("Laying out packaged Rust toolchains")
+
+		versions, err := RustDistVersions(r.Dependencies)
+		if err != nil {
+			return libcnb.Layer{}, err
+		}
+
+		for _, d := range r.Dependencies {
+			if err := r.copyDependency(layer, d, versions); err != nil {
+				return libcnb.Layer{}, err
+			}
+		}
+
+		return layer, nil
+	})
+}
+
+func (r RustDist) Name() string {
+	return r.LayerContributor.Name
+}
+
+// DistServer returns the `file://` URL of the dist layout in the layer
+func (r RustDist) DistServer(layersPath string) string {
+	return (&url.URL{Scheme: "file", Path: filepath.Join(layersPath, r.Name())}).String()
+}
+
+func (r RustDist) copyDependency(layer libcnb.Layer, dependency libpak.BuildpackDependency, versions []*semver.Version) error {
+	artifact, err := r.DependencyCache.Artifact(dependency)
+	if err != nil {
+		return fmt.Errorf("unable to get dependency %s\n%w", dependency.ID, err)
+	}
+	defer artifact.Close()
+
+	if dependency.ID != RustDistManifestID {
+		path, err := distPath(dependency.URI)
+		if err != nil {
+			return err
+		}
+
+		r.Logger.Bodyf("Copying %s", path)
+		if err := sherpa.CopyFile(artifact, filepath.Join(layer.Path, path)); err != nil {
+			return fmt.Errorf("unable to copy %s\n%w", path, err)
+		}
+		return nil
+	}
+
+	raw, err := io.ReadAll(artifact)
+	if err != nil {
+		return fmt.Errorf("unable to read %s\n%w", artifact.Name(), err)
+	}
+
+	v, err := semver.NewVersion(dependency.Version)
+	if err != nil {
+		return fmt.Errorf("unable to parse version %s of %s\n%w", dependency.Version, dependency.ID, err)
+	}
+
+	for _, channel := range distChannels(v, versions) {
+		name := fmt.Sprintf("channel-rust-%s.toml", channel)
+		r.Logger.Bodyf("Copying dist/%s", name)
+
+		if err := os.MkdirAll(filepath.Join(layer.Path, "dist"), 0755); err != nil {
+			return fmt.Errorf("unable to create dist directory\n%w", err)
+		}
+
+		if err := os.WriteFile(filepath.Join(layer.Path, "dist", name), raw, 0644); err != nil {
+			return fmt.Errorf("unable to write %s\n%w", name, err)
+		}
+
+		// rustup verifies the manifest with the hash in `<manifest>.sha256`
+		sum := fmt.Sprintf("%x  %s\n", sha256.Sum256(raw), name)
+		if err := os.WriteFile(filepath.Join(layer.Path, "dist", name+".sha256"), []byte(sum), 0644); err != nil {
+			return fmt.Errorf("unable to write %s.sha256\n%w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// distChannels returns the channel names that resolve to a version, as rustup downloads
+// `dist/channel-rust-<channel>.toml`. `<major>.<minor>` resolves to the latest patch release of that minor version and
+// `stable` to the latest of all versions, which are sorted from oldest to latest.
+func distChannels(v *semver.Version, versions []*semver.Version) []string {
+	channels := []string{v.String()}
+
+	latestPatch := true
+	for _, o := range versions {
+		if o.Major() == v.Major() && o.Minor() == v.Minor() && o.GreaterThan(v) {
+			latestPatch = false
+		}
+	}
+	if latestPatch {
+		channels = append(channels, fmt.Sprintf("%d.%d", v.Major(), v.Minor()))
+	}
+
+	if v.Equal(versions[len(versions)-1]) {
+		channels = append(channels, "stable")
+	}
+
+	return channels
+}
+
+// distPath returns the path of a component archive relative to the dist server, rustup rewrites
+// `https://static.rust-lang.org/dist/2024-05-02/rustc-1.78.0-x86_64-unknown-linux-gnu.tar.xz` to
+// `<dist server>/dist/2024-05-02/rustc-1.78.0-x86_64-unknown-linux-gnu.tar.xz`
+func distPath(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", fmt.Errorf("unable to parse %s\n%w", uri, err)
+	}
+
+	i := strings.LastIndex(u.Path, "/dist/")
+	if i < 0 {
+		return "", fmt.Errorf("%s is not in a dist layout, expected a path like /dist/<date>/<archive>", uri)
+	}
+
+	return u.Path[i+1:], nil
+}
+
+func containsPair(pairs [][2]string, pair [2]string) bool {
+	for _, p := range pairs {
+		if p == pair {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+/*
+ * Copyright 2018-2024 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rustup_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/buildpacks/libcnb"
+	. "github.com/onsi/gomega"
+	"github.com/paketo-buildpacks/libpak"
+	"github.com/paketo-community/rustup/rustup"
+	"github.com/sclevine/spec"
+)
+
+func testRustDist(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		ctx libcnb.BuildContext
+
+		manifest = libpak.BuildpackDependency{
+			ID:      rustup.RustDistManifestID,
+			Version: "1.78.0",
+			URI:     "https://static.rust-lang.org/dist/2024-05-02/channel-rust-1.78.0.toml",
+			SHA256:  "dae88022c22f5eae9983c5ef12b084c193f66fac1b12ac94e7421d417193b8c6",
+			Stacks:  []string{"*"},
+		}
+		rustc = libpak.BuildpackDependency{
+			ID:      "rust-dist-rustc",
+			Version: "1.78.0",
+			URI:     "https://static.rust-lang.org/dist/2024-05-02/rustc-1.78.0-x86_64-unknown-linux-gnu.tar.xz",
+			SHA256:  "6fcd351889eb0caf54c459904e996ac7480a7843a5e8a25c8c6b59a0f975ca19",
+			Stacks:  []string{"*"},
+		}
+	)
+
+	it.Before(func() {
+		var err error
+
+		ctx.Layers.Path, err = os.MkdirTemp("", "rust-dist-layers")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(ctx.Layers.Path)).To(Succeed())
+	})
+
+	context("ResolveRustDistDependencies", func() {
+		it("resolves packaged dependencies for the current architecture", func() {
+			older := manifest
+			older.Version = "1.77.2"
+
+			other := rustc
+			other.PURL = "pkg:generic/rustc@1.78.0?arch=other"
+
+			dr := libpak.DependencyResolver{
+				StackID: "test-stack-id",
+				Dependencies: []libpak.BuildpackDependency{
+					manifest,
+					older,
+					rustc,
+					other,
+					{ID: "rustup-init-gnu", Version: "1.29.0", Stacks: []string{"*"}},
+				},
+			}
+
+			dependencies, err := rustup.ResolveRustDistDependencies(dr)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(dependencies).To(HaveLen(3))
+			Expect(dependencies[0].Version).To(Equal("1.78.0"))
+			Expect(dependencies[1].Version).To(Equal("1.77.2"))
+			Expect(dependencies[2].ID).To(Equal("rust-dist-rustc"))
+			Expect(dependencies[2].PURL).To(BeEmpty())
+		})
+
+		it("resolves nothing without a channel manifest", func() {
+			dr := libpak.DependencyResolver{
+				StackID:      "test-stack-id",
+				Dependencies: []libpak.BuildpackDependency{rustc},
+			}
+
+			dependencies, err := rustup.ResolveRustDistDependencies(dr)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(dependencies).To(BeEmpty())
+		})
+	})
+
+	it("lays out the dist server", func() {
+		r := rustup.NewRustDist([]libpak.BuildpackDependency{manifest, rustc}, libpak.DependencyCache{CachePath: "testdata"})
+
+		layer, err := ctx.Layers.Layer(r.Name())
+		Expect(err).NotTo(HaveOccurred())
+
+		layer, err = r.Contribute(layer)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(layer.LayerTypes.Build).To(BeFalse())
+		Expect(layer.LayerTypes.Cache).To(BeTrue())
+		Expect(layer.LayerTypes.Launch).To(BeFalse())
+
+		for _, channel := range []string{"1.78.0", "1.78", "stable"} {
+			Expect(filepath.Join(layer.Path, "dist", "channel-rust-"+channel+".toml")).To(BeARegularFile())
+		}
+
+		sum, err := os.ReadFile(filepath.Join(layer.Path, "dist", "channel-rust-stable.toml.sha256"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(sum)).To(Equal(manifest.SHA256 + "  channel-rust-stable.toml\n"))
+
+		Expect(filepath.Join(layer.Path, "dist", "2024-05-02", "rustc-1.78.0-x86_64-unknown-linux-gnu.tar.xz")).To(BeARegularFile())
+
+		Expect(r.DistServer(ctx.Layers.Path)).To(Equal("file://" + filepath.Join(ctx.Layers.Path, "rust-dist")))
+	})
+	it("lays out the minor version channel for its latest patch release", func() {
+		patch := libpak.BuildpackDependency{
+			ID:      rustup.RustDistManifestID,
+			Version: "1.78.1",
+			URI:     "https://static.rust-lang.org/dist/2024-05-09/channel-rust-1.78.1.toml",
+			SHA256:  "4f03e686d86c579ecc94047d27b011a72a8ecf1e5f1a3d084df4fbcdd9954fb5",
+			Stacks:  []string{"*"},
+		}
+		r := rustup.NewRustDist([]libpak.BuildpackDependency{patch, manifest}, libpak.DependencyCache{CachePath: "testdata"})
+
+		layer, err := ctx.Layers.Layer(r.Name())
+		Expect(err).NotTo(HaveOccurred())
+
+		layer, err = r.Contribute(layer)
+		Expect(err).NotTo(HaveOccurred())
+
+		for channel, version := range map[string]string{"1.78.0": "1.78.0", "1.78.1": "1.78.1", "1.78": "1.78.1", "stable": "1.78.1"} {
+			raw, err := os.ReadFile(filepath.Join(layer.Path, "dist", "channel-rust-"+channel+".toml"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(raw)).To(ContainSubstring(`version = "`+version+` (`), channel)
+		}
+	})
+}
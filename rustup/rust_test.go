@@ -17,17 +17,23 @@
 package rustup_test
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/BurntSushi/toml"
 	"github.com/buildpacks/libcnb"
 	. "github.com/onsi/gomega"
 	"github.com/paketo-community/rustup/rustup"
 	"github.com/sclevine/spec"
 	"github.com/stretchr/testify/mock"
 
+	"github.com/paketo-buildpacks/libpak/bard"
 	"github.com/paketo-buildpacks/libpak/effect"
 	"github.com/paketo-buildpacks/libpak/effect/mocks"
 )
@@ -64,18 +70,23 @@ func testRust(t *testing.T, context spec.G, it spec.S) {
 
 	it.After(func() {
 		Expect(os.Unsetenv("CARGO_HOME")).To(Succeed())
+		Expect(os.Unsetenv("BP_RUST_TOOLCHAIN_INFO")).To(Succeed())
 		Expect(os.RemoveAll(ctx.Layers.Path)).To(Succeed())
 		Expect(os.RemoveAll(appPath)).To(Succeed())
 	})
 
-	it("contributes rust", func() {
-		layer, err := ctx.Layers.Layer("test-layer")
-		Expect(err).NotTo(HaveOccurred())
-
+	mockRustc := func(layer libcnb.Layer) {
 		executor.On("Execute", mock.MatchedBy(func(ex effect.Execution) bool {
-			return ex.Args[0] == "--version" && ex.Command == "rustc"
+			return ex.Args[0] == "-vV" && ex.Command == "rustc"
 		})).Return(func(ex effect.Execution) error {
-			_, err := ex.Stdout.Write([]byte("rustc 1.2.3 (53cb7b09b 2021-06-17)\n"))
+			_, err := ex.Stdout.Write([]byte(`rustc 1.2.3 (53cb7b09b 2021-06-17)
+binary: rustc
+commit-hash: 53cb7b09b00cbea8754ffb78e7e3cb521cb8af4b
+commit-date: 2021-06-17
+host: x86_64-unknown-linux-gnu
+release: 1.2.3
+LLVM version: 12.0.1
+`))
 			Expect(err).ToNot(HaveOccurred())
 			return nil
 		})
@@ -84,8 +95,15 @@ func testRust(t *testing.T, context spec.G, it spec.S) {
 			Expect(os.MkdirAll(layer.Path, 0755)).To(Succeed())
 			Expect(ioutil.WriteFile(filepath.Join(layer.Path, "env"), nil, 0644)).To(Succeed())
 		})
+	}
+
+	it("contributes rust", func() {
+		layer, err := ctx.Layers.Layer("test-layer")
+		Expect(err).NotTo(HaveOccurred())
 
-		r := rustup.NewRust("minimal", "1.2.3", "", "", false, false)
+		mockRustc(layer)
+
+		r := rustup.NewRust([]rustup.Toolchain{{Name: "1.2.3", Profile: "minimal"}}, "1.2.3")
 		r.Executor = executor
 
 		layer, err = r.Contribute(layer)
@@ -99,180 +117,613 @@ func testRust(t *testing.T, context spec.G, it spec.S) {
 		Expect(execCheck.Command).To(Equal("rustup"))
 		Expect(execCheck.Args).To(Equal([]string{"check"}))
 
-		execShow := executor.Calls[1].Arguments[0].(effect.Execution)
-		Expect(execShow.Command).To(Equal("rustup"))
-		Expect(execShow.Args).To(Equal([]string{"-q", "toolchain", "install", "--profile=minimal", "1.2.3"}))
-		Expect(execShow.Dir).To(Equal(layer.Path))
+		execToolchain := executor.Calls[1].Arguments[0].(effect.Execution)
+		Expect(execToolchain.Command).To(Equal("rustup"))
+		Expect(execToolchain.Args).To(Equal([]string{"-q", "toolchain", "install", "--profile=minimal", "1.2.3"}))
+		Expect(execToolchain.Dir).To(Equal(layer.Path))
 
-		execVer := executor.Calls[2].Arguments[0].(effect.Execution)
+		execDefault := executor.Calls[2].Arguments[0].(effect.Execution)
+		Expect(execDefault.Command).To(Equal("rustup"))
+		Expect(execDefault.Args).To(Equal([]string{"-q", "default", "1.2.3"}))
+		Expect(execDefault.Dir).To(Equal(layer.Path))
+
+		execVer := executor.Calls[3].Arguments[0].(effect.Execution)
 		Expect(execVer.Command).To(Equal("rustc"))
-		Expect(execVer.Args).To(Equal([]string{"--version"}))
+		Expect(execVer.Args).To(Equal([]string{"-vV"}))
 
 		Expect(layer.SBOMPath(libcnb.SyftJSON)).To(BeARegularFile())
+		raw, err := os.ReadFile(layer.SBOMPath(libcnb.CycloneDXJSON))
+		Expect(err).NotTo(HaveOccurred())
+
+		var doc rustup.CycloneDX
+		Expect(json.Unmarshal(raw, &doc)).To(Succeed())
+		Expect(doc.Components[0].PURL).To(Equal("pkg:github/rust-lang/rust@1.2.3?commit=53cb7b09b00cbea8754ffb78e7e3cb521cb8af4b"))
+		Expect(doc.Components[0].CPE).To(Equal("cpe:2.3:a:rust-lang:rust:1.2.3:*:*:*:*:*:*:*"))
+		Expect(doc.Components[0].Properties).To(ContainElements(
+			rustup.CycloneDXProperty{Name: "rust:commit-date", Value: "2021-06-17"},
+			rustup.CycloneDXProperty{Name: "rust:host", Value: "x86_64-unknown-linux-gnu"},
+			rustup.CycloneDXProperty{Name: "rust:llvm-version", Value: "12.0.1"},
+		))
+		Expect(filepath.Join(cargoHome, "bin", "cargo-fmt")).ToNot(BeAnExistingFile())
 	})
 
-	it("contributes rust and a target", func() {
+	it("fails on unexpected rustc output", func() {
 		layer, err := ctx.Layers.Layer("test-layer")
 		Expect(err).NotTo(HaveOccurred())
 
 		executor.On("Execute", mock.MatchedBy(func(ex effect.Execution) bool {
-			return ex.Args[0] == "--version" && ex.Command == "rustc"
+			return ex.Args[0] == "-vV" && ex.Command == "rustc"
 		})).Return(func(ex effect.Execution) error {
-			_, err := ex.Stdout.Write([]byte("rustc 1.2.3 (53cb7b09b 2021-06-17)\n"))
+			_, err := ex.Stdout.Write([]byte("rustc\n"))
 			Expect(err).ToNot(HaveOccurred())
 			return nil
 		})
+		executor.On("Execute", mock.Anything).Return(nil)
 
-		executor.On("Execute", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
-			Expect(os.MkdirAll(layer.Path, 0755)).To(Succeed())
-			Expect(ioutil.WriteFile(filepath.Join(layer.Path, "env"), nil, 0644)).To(Succeed())
-		})
-
-		r := rustup.NewRust("minimal", "1.2.3", "foo", "", false, false)
+		r := rustup.NewRust([]rustup.Toolchain{{Name: "1.2.3", Profile: "minimal"}}, "1.2.3")
 		r.Executor = executor
 
-		layer, err = r.Contribute(layer)
+		_, err = r.Contribute(layer)
+		Expect(err).To(MatchError(ContainSubstring("unable to parse rustc version")))
+	})
+
+	it("contributes rust with targets and components", func() {
+		layer, err := ctx.Layers.Layer("test-layer")
 		Expect(err).NotTo(HaveOccurred())
 
-		Expect(layer.LayerTypes.Build).To(BeTrue())
-		Expect(layer.LayerTypes.Cache).To(BeTrue())
-		Expect(layer.LayerTypes.Launch).To(BeFalse())
+		mockRustc(layer)
 
-		execCheck := executor.Calls[0].Arguments[0].(effect.Execution)
-		Expect(execCheck.Command).To(Equal("rustup"))
-		Expect(execCheck.Args).To(Equal([]string{"check"}))
+		r := rustup.NewRust([]rustup.Toolchain{{
+			Name:       "1.2.3",
+			Profile:    "minimal",
+			Components: []string{"rust-src", "clippy"},
+			Targets:    []string{"wasm32-unknown-unknown", "aarch64-unknown-linux-musl"},
+		}}, "1.2.3")
+		r.Executor = executor
+
+		layer, err = r.Contribute(layer)
+		Expect(err).NotTo(HaveOccurred())
 
 		execToolchain := executor.Calls[1].Arguments[0].(effect.Execution)
 		Expect(execToolchain.Command).To(Equal("rustup"))
-		Expect(execToolchain.Args).To(Equal([]string{"-q", "toolchain", "install", "--profile=minimal", "1.2.3"}))
+		Expect(execToolchain.Args).To(Equal([]string{"-q", "toolchain", "install", "--profile=minimal",
+			"--component=rust-src,clippy", "--target=wasm32-unknown-unknown,aarch64-unknown-linux-musl", "1.2.3"}))
 		Expect(execToolchain.Dir).To(Equal(layer.Path))
 
-		execTarget := executor.Calls[2].Arguments[0].(effect.Execution)
-		Expect(execTarget.Command).To(Equal("rustup"))
-		Expect(execTarget.Args).To(Equal([]string{"-q", "target", "add", "--toolchain=1.2.3", "foo"}))
-		Expect(execTarget.Dir).To(Equal(layer.Path))
-
-		execVer := executor.Calls[3].Arguments[0].(effect.Execution)
-		Expect(execVer.Command).To(Equal("rustc"))
-		Expect(execVer.Args).To(Equal([]string{"--version"}))
+		Expect(layer.Metadata).To(HaveKeyWithValue("toolchains", []map[string]interface{}{
+			{
+				"name":       "1.2.3",
+				"path":       "",
+				"profile":    "minimal",
+				"components": []interface{}{"clippy", "rust-src"},
+				"targets":    []interface{}{"aarch64-unknown-linux-musl", "wasm32-unknown-unknown"},
+			},
+		}))
 
-		Expect(layer.SBOMPath(libcnb.SyftJSON)).To(BeARegularFile())
 	})
 
-	it("contributes rust and a target from rust-toolchain.toml", func() {
+	it("contributes multiple toolchains", func() {
 		layer, err := ctx.Layers.Layer("test-layer")
 		Expect(err).NotTo(HaveOccurred())
 
-		toolchainFilePath := filepath.Join(appPath, "rust-toolchain.toml")
-		Expect(os.WriteFile(toolchainFilePath, []byte("foo"), 0644)).To(Succeed())
+		mockRustc(layer)
 
-		executor.On("Execute", mock.MatchedBy(func(ex effect.Execution) bool {
-			return ex.Args[0] == "--version" && ex.Command == "rustc"
-		})).Return(func(ex effect.Execution) error {
-			_, err := ex.Stdout.Write([]byte("rustc 1.2.3 (53cb7b09b 2021-06-17)\n"))
-			Expect(err).ToNot(HaveOccurred())
-			return nil
-		})
+		r := rustup.NewRust([]rustup.Toolchain{
+			{Name: "stable", Profile: "minimal"},
+			{Name: "nightly-2024-05-01", Profile: "minimal", Components: []string{"miri"}},
+		}, "nightly-2024-05-01")
+		r.Executor = executor
 
-		executor.On("Execute", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
-			Expect(os.MkdirAll(layer.Path, 0755)).To(Succeed())
-			Expect(ioutil.WriteFile(filepath.Join(layer.Path, "env"), nil, 0644)).To(Succeed())
-		})
+		layer, err = r.Contribute(layer)
+		Expect(err).NotTo(HaveOccurred())
+
+		execStable := executor.Calls[1].Arguments[0].(effect.Execution)
+		Expect(execStable.Args).To(Equal([]string{"-q", "toolchain", "install", "--profile=minimal", "stable"}))
 
-		r := rustup.NewRust("minimal", "1.2.3", "foo", toolchainFilePath, false, false)
+		execNightly := executor.Calls[2].Arguments[0].(effect.Execution)
+		Expect(execNightly.Args).To(Equal([]string{"-q", "toolchain", "install", "--profile=minimal", "--component=miri", "nightly-2024-05-01"}))
+
+		execDefault := executor.Calls[3].Arguments[0].(effect.Execution)
+		Expect(execDefault.Args).To(Equal([]string{"-q", "default", "nightly-2024-05-01"}))
+
+		Expect(layer.Metadata).To(HaveKeyWithValue("default", "nightly-2024-05-01"))
+		Expect(layer.Metadata["toolchains"]).To(HaveLen(2))
+	})
+
+	it("links a custom toolchain", func() {
+		layer, err := ctx.Layers.Layer("test-layer")
+		Expect(err).NotTo(HaveOccurred())
+
+		mockRustc(layer)
+
+		r := rustup.NewRust([]rustup.Toolchain{{Name: "local", Path: filepath.Join(appPath, "toolchain")}}, "local")
 		r.Executor = executor
 
 		layer, err = r.Contribute(layer)
 		Expect(err).NotTo(HaveOccurred())
 
-		Expect(layer.LayerTypes.Build).To(BeTrue())
-		Expect(layer.LayerTypes.Cache).To(BeTrue())
-		Expect(layer.LayerTypes.Launch).To(BeFalse())
-
-		execCheck := executor.Calls[0].Arguments[0].(effect.Execution)
-		Expect(execCheck.Command).To(Equal("rustup"))
-		Expect(execCheck.Args).To(Equal([]string{"check"}))
+		execLink := executor.Calls[1].Arguments[0].(effect.Execution)
+		Expect(execLink.Command).To(Equal("rustup"))
+		Expect(execLink.Args).To(Equal([]string{"toolchain", "link", "local", filepath.Join(appPath, "toolchain")}))
 
-		execDefault := executor.Calls[1].Arguments[0].(effect.Execution)
+		execDefault := executor.Calls[2].Arguments[0].(effect.Execution)
 		Expect(execDefault.Command).To(Equal("rustup"))
-		Expect(execDefault.Args).To(Equal([]string{"-q", "default", "1.2.3"}))
-		Expect(execDefault.Dir).To(Equal(layer.Path))
+		Expect(execDefault.Args).To(Equal([]string{"-q", "default", "local"}))
+	})
 
-		execShow := executor.Calls[2].Arguments[0].(effect.Execution)
-		Expect(execShow.Command).To(Equal("rustup"))
-		Expect(execShow.Args).To(Equal([]string{"-q", "show"}))
-		Expect(execShow.Dir).To(Equal(layer.Path))
+	context("installed toolchains", func() {
+		var rustupHome string
 
-		execTarget := executor.Calls[3].Arguments[0].(effect.Execution)
-		Expect(execTarget.Command).To(Equal("rustup"))
-		Expect(execTarget.Args).To(Equal([]string{"-q", "target", "add", "--toolchain=1.2.3", "foo"}))
-		Expect(execTarget.Dir).To(Equal(layer.Path))
+		it.Before(func() {
+			var err error
 
-		execVer := executor.Calls[4].Arguments[0].(effect.Execution)
-		Expect(execVer.Command).To(Equal("rustc"))
-		Expect(execVer.Args).To(Equal([]string{"--version"}))
+			rustupHome, err = os.MkdirTemp("", "rustup-home")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(os.Setenv("RUSTUP_HOME", rustupHome)).To(Succeed())
 
-		Expect(layer.SBOMPath(libcnb.SyftJSON)).To(BeARegularFile())
+			dir := filepath.Join(rustupHome, "toolchains", "1.78.0-x86_64-unknown-linux-gnu", "lib", "rustlib")
+			Expect(os.MkdirAll(dir, 0755)).To(Succeed())
+			raw, err := os.ReadFile(filepath.Join("testdata", "multirust-channel-manifest.toml"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(os.WriteFile(filepath.Join(dir, "multirust-channel-manifest.toml"), raw, 0644)).To(Succeed())
+			Expect(os.Symlink(filepath.Join(rustupHome, "toolchains", "1.78.0-x86_64-unknown-linux-gnu"),
+				filepath.Join(rustupHome, "toolchains", "stable-x86_64-unknown-linux-gnu"))).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("RUSTUP_HOME")).To(Succeed())
+			Expect(os.RemoveAll(rustupHome)).To(Succeed())
+		})
+
+		it("records the resolved toolchains", func() {
+			layer, err := ctx.Layers.Layer("test-layer")
+			Expect(err).NotTo(HaveOccurred())
+
+			mockRustc(layer)
+
+			r := rustup.NewRust([]rustup.Toolchain{{Name: "stable", Profile: "minimal"}}, "stable")
+			r.Executor = executor
+
+			layer, err = r.Contribute(layer)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(layer.Metadata["manifests"]).To(HaveKey("stable"))
+			manifest := layer.Metadata["manifests"].(map[string]interface{})["stable"]
+			Expect(manifest).To(HaveKeyWithValue("channel", "1.78.0"))
+			Expect(manifest).To(HaveKeyWithValue("date", "2024-05-02"))
+			Expect(manifest).To(HaveKey("hash"))
+		})
+
+		it("lists the installed components in the SBOM", func() {
+			layer, err := ctx.Layers.Layer("test-layer")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(os.WriteFile(filepath.Join(rustupHome, "toolchains", "1.78.0-x86_64-unknown-linux-gnu", "lib", "rustlib", "components"),
+				[]byte("rustc-x86_64-unknown-linux-gnu\nrust-std-wasm32-unknown-unknown\nrust-src\n"), 0644)).To(Succeed())
+
+			mockRustc(layer)
+
+			r := rustup.NewRust([]rustup.Toolchain{{Name: "stable", Profile: "minimal"}}, "stable")
+			r.Executor = executor
+
+			layer, err = r.Contribute(layer)
+			Expect(err).NotTo(HaveOccurred())
+
+			raw, err := os.ReadFile(layer.SBOMPath(libcnb.SyftJSON))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(raw)).To(ContainSubstring("pkg:generic/rustc@1.78.0?checksum=sha256:e59ce26bfc09de0c2d08192a3b4c5d6e7f8091a2b3c4d5e6f708192a3b4c5d6e\\u0026target=x86_64-unknown-linux-gnu"))
+			Expect(string(raw)).To(ContainSubstring("pkg:generic/rust-std@1.78.0?checksum=sha256:a15eae27b8c5fac8c9c4d5e6f708192a3b4c5d6e7f8091a2b3c4d5e6f708192a\\u0026target=wasm32-unknown-unknown"))
+			Expect(string(raw)).To(ContainSubstring("pkg:generic/rust-src@1.78.0?checksum=sha256:07b1c2d3e4f5061728394a5b6c7d8e9f0a1b2c3d4e5f60718293a4b5c6d7e8f9\""))
+
+			raw, err = os.ReadFile(layer.SBOMPath(libcnb.CycloneDXJSON))
+			Expect(err).NotTo(HaveOccurred())
+
+			var doc rustup.CycloneDX
+			Expect(json.Unmarshal(raw, &doc)).To(Succeed())
+			Expect(doc.Components).To(HaveLen(4))
+			Expect(doc.Components[1].BOMRef).To(Equal("rust-stable-rustc-x86_64-unknown-linux-gnu"))
+			Expect(doc.Components[1].Hashes).To(Equal([]rustup.CycloneDXHash{
+				{Algorithm: "SHA-256", Content: "e59ce26bfc09de0c2d08192a3b4c5d6e7f8091a2b3c4d5e6f708192a3b4c5d6e"},
+			}))
+			Expect(doc.Components[3].BOMRef).To(Equal("rust-stable-rust-src"))
+		})
+
+		it("skips components missing from the channel manifest in the SBOM", func() {
+			layer, err := ctx.Layers.Layer("test-layer")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(os.WriteFile(filepath.Join(rustupHome, "toolchains", "1.78.0-x86_64-unknown-linux-gnu", "lib", "rustlib", "components"),
+				[]byte("rustc-x86_64-unknown-linux-gnu\nmiri-x86_64-unknown-linux-gnu\n"), 0644)).To(Succeed())
+
+			mockRustc(layer)
+
+			r := rustup.NewRust([]rustup.Toolchain{{Name: "stable", Profile: "minimal"}}, "stable")
+			r.Executor = executor
+
+			layer, err = r.Contribute(layer)
+			Expect(err).NotTo(HaveOccurred())
+
+			raw, err := os.ReadFile(layer.SBOMPath(libcnb.CycloneDXJSON))
+			Expect(err).NotTo(HaveOccurred())
+
+			var doc rustup.CycloneDX
+			Expect(json.Unmarshal(raw, &doc)).To(Succeed())
+			Expect(doc.Components).To(HaveLen(2))
+			Expect(doc.Components[1].BOMRef).To(Equal("rust-stable-rustc-x86_64-unknown-linux-gnu"))
+		})
+
+		it("logs toolchains without a component inventory", func() {
+			layer, err := ctx.Layers.Layer("test-layer")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(os.MkdirAll(filepath.Join(rustupHome, "toolchains", "nightly-x86_64-unknown-linux-gnu"), 0755)).To(Succeed())
+
+			mockRustc(layer)
+
+			buf := &bytes.Buffer{}
+			r := rustup.NewRust([]rustup.Toolchain{
+				{Name: "stable", Profile: "minimal", Components: []string{"clippy"}},
+				{Name: "nightly", Profile: "minimal"},
+			}, "stable")
+			r.Executor = executor
+			r.Logger = bard.NewLogger(buf)
+
+			layer, err = r.Contribute(layer)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(buf.String()).To(ContainSubstring("the component inventory of toolchain stable is unavailable"))
+			Expect(buf.String()).To(ContainSubstring("the component inventory of toolchain nightly is unavailable"))
+
+			raw, err := os.ReadFile(layer.SBOMPath(libcnb.CycloneDXJSON))
+			Expect(err).NotTo(HaveOccurred())
+
+			var doc rustup.CycloneDX
+			Expect(json.Unmarshal(raw, &doc)).To(Succeed())
+			Expect(doc.Components).To(HaveLen(1))
+			Expect(doc.Components[0].BOMRef).To(Equal("rust"))
+		})
+
+		it("writes the toolchain info", func() {
+			layer, err := ctx.Layers.Layer("test-layer")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(os.WriteFile(filepath.Join(rustupHome, "toolchains", "1.78.0-x86_64-unknown-linux-gnu", "lib", "rustlib", "components"),
+				[]byte("rustc-x86_64-unknown-linux-gnu\nrust-std-x86_64-unknown-linux-gnu\nrust-std-wasm32-unknown-unknown\nrust-src\n"), 0644)).To(Succeed())
+
+			mockRustc(layer)
+
+			r := rustup.NewRust([]rustup.Toolchain{
+				{Name: "stable", Profile: "minimal", Targets: []string{"wasm32-unknown-unknown"}},
+				{Name: "local", Path: "/opt/rust"},
+			}, "stable")
+			r.Executor = executor
+
+			layer, err = r.Contribute(layer)
+			Expect(err).NotTo(HaveOccurred())
+
+			path := filepath.Join(layer.Path, "toolchain.json")
+			Expect(layer.BuildEnvironment["BP_RUST_TOOLCHAIN_INFO.override"]).To(Equal(path))
+			Expect(os.Getenv("BP_RUST_TOOLCHAIN_INFO")).To(Equal(path))
+
+			info, err := rustup.ReadToolchainInfo(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(info).To(Equal(rustup.ToolchainInfo{
+				DefaultToolchain: "stable",
+				Host:             "x86_64-unknown-linux-gnu",
+				RustupHome:       rustupHome,
+				CargoHome:        cargoHome,
+				Toolchains: []rustup.InstalledToolchain{
+					{
+						Name:       "stable",
+						Channel:    "1.78.0",
+						Version:    "1.78.0",
+						Path:       filepath.Join(rustupHome, "toolchains", "stable-x86_64-unknown-linux-gnu"),
+						Targets:    []string{"wasm32-unknown-unknown", "x86_64-unknown-linux-gnu"},
+						Components: []string{"rust-src", "rust-std", "rustc"},
+					},
+					{
+						Name:       "local",
+						Path:       "/opt/rust",
+						Linked:     true,
+						Targets:    []string{},
+						Components: []string{},
+					},
+				},
+			}))
+		})
+
+		it("writes the toolchain info when the layer is restored", func() {
+			layer, err := ctx.Layers.Layer("test-layer")
+			Expect(err).NotTo(HaveOccurred())
+
+			mockRustc(layer)
+
+			r := rustup.NewRust([]rustup.Toolchain{{Name: "stable", Profile: "minimal"}}, "stable")
+			r.Executor = executor
+			r.UpdatePolicy = rustup.UpdatePolicy{Mode: rustup.UpdatePolicyNever}
+
+			layer, err = r.Contribute(layer)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(os.Remove(filepath.Join(layer.Path, "toolchain.json"))).To(Succeed())
+
+			// the previous metadata is read back from layer toml
+			buf := &bytes.Buffer{}
+			Expect(toml.NewEncoder(buf).Encode(r.LayerContributor.ExpectedMetadata)).To(Succeed())
+			layer.Metadata = map[string]interface{}{}
+			_, err = toml.Decode(buf.String(), &layer.Metadata)
+			Expect(err).NotTo(HaveOccurred())
+			layer, err = r.Contribute(layer)
+			Expect(err).NotTo(HaveOccurred())
+
+			installs := 0
+			for _, call := range executor.Calls {
+				if ex := call.Arguments[0].(effect.Execution); ex.Command == "rustup" && len(ex.Args) > 1 && ex.Args[1] == "toolchain" {
+					installs++
+				}
+			}
+			Expect(installs).To(Equal(1))
+
+			info, err := rustup.ReadToolchainInfo(filepath.Join(layer.Path, "toolchain.json"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(info.Host).To(Equal("x86_64-unknown-linux-gnu"))
+			Expect(info.Toolchains[0].Components).To(BeEmpty())
+		})
+
+		it("warns about toolchains affected by advisories", func() {
+			layer, err := ctx.Layers.Layer("test-layer")
+			Expect(err).NotTo(HaveOccurred())
+
+			mockRustc(layer)
+
+			advisories, err := rustup.ReadAdvisories(filepath.Join("testdata", "advisory-db"))
+			Expect(err).NotTo(HaveOccurred())
+
+			r := rustup.NewRust([]rustup.Toolchain{{Name: "stable", Profile: "minimal"}}, "stable")
+			r.Executor = executor
+			r.Advisories = advisories
+
+			_, err = r.Contribute(layer)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		it("fails when toolchains are affected by advisories", func() {
+			layer, err := ctx.Layers.Layer("test-layer")
+			Expect(err).NotTo(HaveOccurred())
+
+			mockRustc(layer)
+
+			advisories, err := rustup.ReadAdvisories(filepath.Join("testdata", "advisory-db"))
+			Expect(err).NotTo(HaveOccurred())
+
+			r := rustup.NewRust([]rustup.Toolchain{{Name: "stable", Profile: "minimal"}}, "stable")
+			r.Executor = executor
+			r.Advisories = advisories
+			r.AdvisoryFail = true
+
+			_, err = r.Contribute(layer)
+			Expect(err).To(MatchError("toolchains are affected by advisories: stable (CVE-2024-99999), set BP_RUST_ADVISORY_FAIL=false to continue"))
+		})
+
+		it("warns about a toolchain older than the minimum version", func() {
+			layer, err := ctx.Layers.Layer("test-layer")
+			Expect(err).NotTo(HaveOccurred())
+
+			mockRustc(layer)
+
+			r := rustup.NewRust([]rustup.Toolchain{{Name: "stable", Profile: "minimal"}}, "stable")
+			r.Executor = executor
+			r.Requirements = []rustup.RustVersionRequirement{{Source: "Cargo.toml rust-version", Version: "1.80"}}
+
+			_, err = r.Contribute(layer)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		it("fails on a toolchain older than the minimum version when strict", func() {
+			layer, err := ctx.Layers.Layer("test-layer")
+			Expect(err).NotTo(HaveOccurred())
+
+			mockRustc(layer)
+
+			r := rustup.NewRust([]rustup.Toolchain{{Name: "stable", Profile: "minimal"}}, "stable")
+			r.Executor = executor
+			r.Requirements = []rustup.RustVersionRequirement{
+				{Source: "Cargo.toml rust-version", Version: "1.70"},
+				{Source: "$BP_RUST_MIN_VERSION", Version: "1.80.0"},
+			}
+			r.RequirementsFail = true
+
+			_, err = r.Contribute(layer)
+			Expect(err).To(MatchError("toolchain stable (rustc 1.78.0) is older than $BP_RUST_MIN_VERSION 1.80.0, set BP_RUST_VERSION_STRICT=false to continue"))
+		})
+
+		it("passes a toolchain satisfying the minimum version when strict", func() {
+			layer, err := ctx.Layers.Layer("test-layer")
+			Expect(err).NotTo(HaveOccurred())
+
+			mockRustc(layer)
+
+			r := rustup.NewRust([]rustup.Toolchain{{Name: "stable", Profile: "minimal"}}, "stable")
+			r.Executor = executor
+			r.Requirements = []rustup.RustVersionRequirement{{Source: "Cargo.toml rust-version", Version: "1.78"}}
+			r.RequirementsFail = true
+
+			_, err = r.Contribute(layer)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		it("writes the toolchain lock", func() {
+			layer, err := ctx.Layers.Layer("test-layer")
+			Expect(err).NotTo(HaveOccurred())
+
+			mockRustc(layer)
+
+			r := rustup.NewRust([]rustup.Toolchain{{Name: "stable", Profile: "minimal"}}, "stable")
+			r.Executor = executor
+			r.LockMode = rustup.LockModeWrite
+			r.LockPath = filepath.Join(appPath, rustup.LockFileName)
+
+			_, err = r.Contribute(layer)
+			Expect(err).NotTo(HaveOccurred())
+
+			lock, ok, err := rustup.ReadToolchainLock(r.LockPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			Expect(lock.Toolchains).To(HaveLen(1))
+			Expect(lock.Toolchains[0].Name).To(Equal("stable"))
+			Expect(lock.Toolchains[0].Channel).To(Equal("1.78.0"))
+			Expect(lock.Toolchains[0].Date).To(Equal("2024-05-02"))
+		})
+
+		it("locks a toolchain resolved from a range by the range", func() {
+			layer, err := ctx.Layers.Layer("test-layer")
+			Expect(err).NotTo(HaveOccurred())
+
+			mockRustc(layer)
+
+			r := rustup.NewRust([]rustup.Toolchain{{Name: "1.78.0", Range: "1.78.*", Profile: "minimal"}}, "1.78.0")
+			r.Executor = executor
+			r.LockMode = rustup.LockModeWrite
+			r.LockPath = filepath.Join(appPath, rustup.LockFileName)
+
+			_, err = r.Contribute(layer)
+			Expect(err).NotTo(HaveOccurred())
+
+			lock, _, err := rustup.ReadToolchainLock(r.LockPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(lock.Toolchains).To(HaveLen(1))
+			Expect(lock.Toolchains[0].Name).To(Equal("1.78.*"))
+			Expect(lock.Toolchains[0].Channel).To(Equal("1.78.0"))
+		})
+
+		it("installs and verifies the pinned toolchain", func() {
+			layer, err := ctx.Layers.Layer("test-layer")
+			Expect(err).NotTo(HaveOccurred())
+
+			mockRustc(layer)
+
+			m, err := rustup.ReadChannelManifest(filepath.Join("testdata", "multirust-channel-manifest.toml"))
+			Expect(err).NotTo(HaveOccurred())
+
+			r := rustup.NewRust([]rustup.Toolchain{{Name: "stable", Profile: "minimal", Pinned: "1.78.0"}}, "stable")
+			r.Executor = executor
+			r.LockMode = rustup.LockModeRead
+			r.Lock = rustup.ToolchainLock{Toolchains: []rustup.LockedToolchain{{Name: "stable", Channel: "1.78.0", Hash: m.Hash}}}
+
+			_, err = r.Contribute(layer)
+			Expect(err).NotTo(HaveOccurred())
+
+			execToolchain := executor.Calls[1].Arguments[0].(effect.Execution)
+			Expect(execToolchain.Args).To(Equal([]string{"-q", "toolchain", "install", "--profile=minimal", "1.78.0"}))
+
+			execDefault := executor.Calls[2].Arguments[0].(effect.Execution)
+			Expect(execDefault.Args).To(Equal([]string{"-q", "default", "1.78.0"}))
+		})
+
+		it("fails when the pinned toolchain does not match the lock", func() {
+			layer, err := ctx.Layers.Layer("test-layer")
+			Expect(err).NotTo(HaveOccurred())
+
+			mockRustc(layer)
+
+			r := rustup.NewRust([]rustup.Toolchain{{Name: "stable", Profile: "minimal", Pinned: "1.78.0"}}, "stable")
+			r.Executor = executor
+			r.LockMode = rustup.LockModeRead
+			r.Lock = rustup.ToolchainLock{Toolchains: []rustup.LockedToolchain{{Name: "stable", Channel: "1.78.0", Hash: "abc"}}}
+
+			_, err = r.Contribute(layer)
+			Expect(err).To(MatchError(ContainSubstring("but rust-toolchain.lock expects abc")))
+		})
 	})
 
-	it("contributes rust and a target from rust-toolchain.toml and from env variable", func() {
+	it("does not fail when the update check fails", func() {
 		layer, err := ctx.Layers.Layer("test-layer")
 		Expect(err).NotTo(HaveOccurred())
 
-		toolchainFilePath := filepath.Join(appPath, "rust-toolchain.toml")
-		Expect(os.WriteFile(toolchainFilePath, []byte("foo"), 0644)).To(Succeed())
-
 		executor.On("Execute", mock.MatchedBy(func(ex effect.Execution) bool {
-			return ex.Args[0] == "--version" && ex.Command == "rustc"
-		})).Return(func(ex effect.Execution) error {
-			_, err := ex.Stdout.Write([]byte("rustc 1.2.3 (53cb7b09b 2021-06-17)\n"))
-			Expect(err).ToNot(HaveOccurred())
-			return nil
-		})
+			return len(ex.Args) > 0 && ex.Args[0] == "check"
+		})).Return(fmt.Errorf("exit status 1"))
+		mockRustc(layer)
 
-		executor.On("Execute", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
-			Expect(os.MkdirAll(layer.Path, 0755)).To(Succeed())
-			Expect(ioutil.WriteFile(filepath.Join(layer.Path, "env"), nil, 0644)).To(Succeed())
-		})
+		r := rustup.NewRust([]rustup.Toolchain{{Name: "stable", Profile: "minimal"}}, "stable")
+		r.Executor = executor
+
+		layer, err = r.Contribute(layer)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(layer.Metadata).NotTo(HaveKey("installed"))
+	})
+
+	it("skips the update check with the never policy", func() {
+		layer, err := ctx.Layers.Layer("test-layer")
+		Expect(err).NotTo(HaveOccurred())
+
+		mockRustc(layer)
 
-		r := rustup.NewRust("minimal", "1.2.3", "foo", toolchainFilePath, true, true)
+		r := rustup.NewRust([]rustup.Toolchain{{Name: "stable", Profile: "minimal"}}, "stable")
 		r.Executor = executor
+		r.UpdatePolicy = rustup.UpdatePolicy{Mode: rustup.UpdatePolicyNever}
 
 		layer, err = r.Contribute(layer)
 		Expect(err).NotTo(HaveOccurred())
 
-		Expect(layer.LayerTypes.Build).To(BeTrue())
-		Expect(layer.LayerTypes.Cache).To(BeTrue())
-		Expect(layer.LayerTypes.Launch).To(BeFalse())
+		execToolchain := executor.Calls[0].Arguments[0].(effect.Execution)
+		Expect(execToolchain.Args).To(Equal([]string{"-q", "toolchain", "install", "--profile=minimal", "stable"}))
+		Expect(layer.Metadata).NotTo(HaveKey("installed"))
+	})
 
-		execCheck := executor.Calls[0].Arguments[0].(effect.Execution)
-		Expect(execCheck.Command).To(Equal("rustup"))
-		Expect(execCheck.Args).To(Equal([]string{"check"}))
+	it("keeps the install time within the update interval", func() {
+		layer, err := ctx.Layers.Layer("test-layer")
+		Expect(err).NotTo(HaveOccurred())
 
-		execDefault := executor.Calls[1].Arguments[0].(effect.Execution)
-		Expect(execDefault.Command).To(Equal("rustup"))
-		Expect(execDefault.Args).To(Equal([]string{"-q", "default", "1.2.3"}))
-		Expect(execDefault.Dir).To(Equal(layer.Path))
+		installedAt := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+		layer.Metadata = map[string]interface{}{"installed-at": installedAt}
 
-		execShow := executor.Calls[2].Arguments[0].(effect.Execution)
-		Expect(execShow.Command).To(Equal("rustup"))
-		Expect(execShow.Args).To(Equal([]string{"-q", "show"}))
-		Expect(execShow.Dir).To(Equal(layer.Path))
+		mockRustc(layer)
 
-		execToolchain := executor.Calls[3].Arguments[0].(effect.Execution)
-		Expect(execToolchain.Command).To(Equal("rustup"))
-		Expect(execToolchain.Args).To(Equal([]string{"-q", "toolchain", "install", "--profile=minimal", "1.2.3"}))
-		Expect(execToolchain.Dir).To(Equal(layer.Path))
+		r := rustup.NewRust([]rustup.Toolchain{{Name: "stable", Profile: "minimal"}}, "stable")
+		r.Executor = executor
+		r.UpdatePolicy = rustup.UpdatePolicy{Mode: rustup.UpdatePolicyInterval, Interval: 24 * time.Hour}
 
-		execTarget := executor.Calls[4].Arguments[0].(effect.Execution)
-		Expect(execTarget.Command).To(Equal("rustup"))
-		Expect(execTarget.Args).To(Equal([]string{"-q", "target", "add", "--toolchain=1.2.3", "foo"}))
-		Expect(execTarget.Dir).To(Equal(layer.Path))
+		layer, err = r.Contribute(layer)
+		Expect(err).NotTo(HaveOccurred())
 
-		execVer := executor.Calls[5].Arguments[0].(effect.Execution)
-		Expect(execVer.Command).To(Equal("rustc"))
-		Expect(execVer.Args).To(Equal([]string{"--version"}))
+		Expect(layer.Metadata).To(HaveKeyWithValue("installed-at", installedAt))
+		for _, call := range executor.Calls {
+			Expect(call.Arguments[0].(effect.Execution).Args).NotTo(Equal([]string{"check"}))
+		}
+	})
 
-		Expect(layer.SBOMPath(libcnb.SyftJSON)).To(BeARegularFile())
+	it("refreshes the install time once the update interval has passed", func() {
+		layer, err := ctx.Layers.Layer("test-layer")
+		Expect(err).NotTo(HaveOccurred())
+
+		installedAt := time.Now().Add(-48 * time.Hour).UTC().Format(time.RFC3339)
+		layer.Metadata = map[string]interface{}{"installed-at": installedAt}
+
+		mockRustc(layer)
+
+		r := rustup.NewRust([]rustup.Toolchain{{Name: "stable", Profile: "minimal"}}, "stable")
+		r.Executor = executor
+		r.UpdatePolicy = rustup.UpdatePolicy{Mode: rustup.UpdatePolicyInterval, Interval: 24 * time.Hour}
+
+		layer, err = r.Contribute(layer)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(layer.Metadata["installed-at"]).NotTo(Equal(installedAt))
+	})
+
+	it("fails when a component is not available", func() {
+		layer, err := ctx.Layers.Layer("test-layer")
+		Expect(err).NotTo(HaveOccurred())
+
+		executor.On("Execute", mock.MatchedBy(func(ex effect.Execution) bool {
+			return len(ex.Args) > 1 && ex.Args[1] == "toolchain"
+		})).Return(fmt.Errorf("exit status 1"))
+		executor.On("Execute", mock.Anything).Return(nil)
+
+		r := rustup.NewRust([]rustup.Toolchain{{Name: "nightly", Profile: "minimal", Components: []string{"miri"}}}, "nightly")
+		r.Executor = executor
+
+		_, err = r.Contribute(layer)
+		Expect(err).To(MatchError(ContainSubstring("one of miri may not be available for toolchain nightly")))
 	})
 }
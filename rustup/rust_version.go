@@ -0,0 +1,147 @@
+/*
+ * Copyright 2018-2024 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rustup
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+	"github.com/Masterminds/semver/v3"
+	"github.com/paketo-buildpacks/libpak"
+)
+
+// ToolchainMSRV is the value of $BP_RUST_TOOLCHAIN that installs the `rust-version` of the application `Cargo.toml`
+const ToolchainMSRV = "msrv"
+
+// RustVersionRequirement is a minimum Rust version the default toolchain must have
+type RustVersionRequirement struct {
+	// Source is where the requirement is configured, like `Cargo.toml rust-version`
+	Source string
+
+	// Version is the minimum Rust version, like `1.70` or `1.70.0`
+	Version string
+}
+
+// ResolveRustVersionRequirements returns the minimum Rust versions from the `rust-version` of the application
+// `Cargo.toml` and from $BP_RUST_MIN_VERSION
+func ResolveRustVersionRequirements(cr libpak.ConfigurationResolver, appPath string) ([]RustVersionRequirement, error) {
+	var requirements []RustVersionRequirement
+
+	msrv, ok, err := ReadCargoRustVersion(filepath.Join(appPath, "Cargo.toml"))
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		requirements = append(requirements, RustVersionRequirement{Source: "Cargo.toml rust-version", Version: msrv})
+	}
+
+	if val, _ := cr.Resolve("BP_RUST_MIN_VERSION"); val != "" {
+		requirements = append(requirements, RustVersionRequirement{Source: "$BP_RUST_MIN_VERSION", Version: val})
+	}
+
+	for _, r := range requirements {
+		if _, err := semver.NewVersion(r.Version); err != nil {
+			return nil, fmt.Errorf("invalid %s %q, must be a Rust version like 1.70 or 1.70.0\n%w", r.Source, r.Version, err)
+		}
+	}
+
+	return requirements, nil
+}
+
+type cargoManifest struct {
+	Package struct {
+		RustVersion interface{} `toml:"rust-version"`
+	} `toml:"package"`
+	Workspace struct {
+		Package struct {
+			RustVersion interface{} `toml:"rust-version"`
+		} `toml:"package"`
+	} `toml:"workspace"`
+}
+
+// ReadCargoRustVersion returns the `rust-version` of the package in a `Cargo.toml`, or of the workspace if the
+// package inherits it or there is no package. It returns false if the file does not exist or has no `rust-version`.
+func ReadCargoRustVersion(path string) (string, bool, error) {
+	var m cargoManifest
+	if _, err := toml.DecodeFile(path, &m); errors.Is(err, fs.ErrNotExist) {
+		return "", false, nil
+	} else if err != nil {
+		return "", false, fmt.Errorf("unable to decode %s\n%w", path, err)
+	}
+
+	if v, ok := m.Package.RustVersion.(string); ok && v != "" {
+		return v, true, nil
+	}
+
+	if v, ok := m.Workspace.Package.RustVersion.(string); ok && v != "" {
+		return v, true, nil
+	}
+
+	return "", false, nil
+}
+
+// MSRVToolchain returns the exact Rust version declared as `rust-version` in the application `Cargo.toml`, like
+// `1.70.0` for `1.70`, so that exactly the minimum supported Rust version is installed
+func MSRVToolchain(appPath string) (string, error) {
+	path := filepath.Join(appPath, "Cargo.toml")
+
+	msrv, ok, err := ReadCargoRustVersion(path)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", fmt.Errorf("unable to find package.rust-version or workspace.package.rust-version in %s, which is required by BP_RUST_TOOLCHAIN=%s", path, ToolchainMSRV)
+	}
+
+	v, err := semver.NewVersion(msrv)
+	if err != nil {
+		return "", fmt.Errorf("invalid rust-version %q in %s, must be a Rust version like 1.70 or 1.70.0\n%w", msrv, path, err)
+	}
+	if v.Prerelease() != "" || v.Metadata() != "" {
+		return "", fmt.Errorf("invalid rust-version %q in %s, must not have a pre-release or build metadata", msrv, path)
+	}
+
+	return v.String(), nil
+}
+
+// Satisfied returns true if a Rust version is at least the required version. Pre-release versions, like
+// `1.80.0-nightly`, are compared by their release version.
+func (r RustVersionRequirement) Satisfied(version string) (bool, error) {
+	v, err := semver.NewVersion(version)
+	if err != nil {
+		return false, fmt.Errorf("unable to parse version %s\n%w", version, err)
+	}
+
+	release, err := v.SetPrerelease("")
+	if err != nil {
+		return false, fmt.Errorf("unable to remove pre-release from %s\n%w", version, err)
+	}
+
+	min, err := semver.NewVersion(r.Version)
+	if err != nil {
+		return false, fmt.Errorf("unable to parse %s %s\n%w", r.Source, r.Version, err)
+	}
+
+	return !release.LessThan(min), nil
+}
+
+func (r RustVersionRequirement) String() string {
+	return fmt.Sprintf("%s %s", r.Source, r.Version)
+}
@@ -0,0 +1,126 @@
+/*
+ * Copyright 2018-2024 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rustup_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/paketo-community/rustup/rustup"
+	"github.com/sclevine/spec"
+)
+
+func testRustVersion(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		path string
+	)
+
+	it.Before(func() {
+		path = filepath.Join(t.TempDir(), "Cargo.toml")
+	})
+
+	context("ReadCargoRustVersion", func() {
+		it("returns false without a Cargo.toml", func() {
+			_, ok, err := rustup.ReadCargoRustVersion(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeFalse())
+		})
+
+		it("returns false without a rust-version", func() {
+			Expect(os.WriteFile(path, []byte("[package]\nname = \"app\"\n"), 0644)).To(Succeed())
+
+			_, ok, err := rustup.ReadCargoRustVersion(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeFalse())
+		})
+
+		it("reads the rust-version of the package", func() {
+			Expect(os.WriteFile(path, []byte("[package]\nname = \"app\"\nrust-version = \"1.70\"\n"), 0644)).To(Succeed())
+
+			v, ok, err := rustup.ReadCargoRustVersion(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			Expect(v).To(Equal("1.70"))
+		})
+
+		it("reads the rust-version inherited from the workspace", func() {
+			Expect(os.WriteFile(path, []byte(`[workspace.package]
+rust-version = "1.74.1"
+
+[package]
+name = "app"
+rust-version.workspace = true
+`), 0644)).To(Succeed())
+
+			v, ok, err := rustup.ReadCargoRustVersion(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			Expect(v).To(Equal("1.74.1"))
+		})
+
+		it("fails on an invalid Cargo.toml", func() {
+			Expect(os.WriteFile(path, []byte("[package\n"), 0644)).To(Succeed())
+
+			_, _, err := rustup.ReadCargoRustVersion(path)
+			Expect(err).To(MatchError(ContainSubstring("unable to decode")))
+		})
+	})
+
+	context("Satisfied", func() {
+		requirement := rustup.RustVersionRequirement{Source: "Cargo.toml rust-version", Version: "1.70"}
+
+		it("is satisfied by the same and newer versions", func() {
+			Expect(requirement.Satisfied("1.70.0")).To(BeTrue())
+			Expect(requirement.Satisfied("1.78.0")).To(BeTrue())
+		})
+
+		it("is not satisfied by older versions", func() {
+			Expect(requirement.Satisfied("1.69.0")).To(BeFalse())
+		})
+
+		it("compares pre-release versions by their release", func() {
+			Expect(requirement.Satisfied("1.70.0-nightly")).To(BeTrue())
+			Expect(requirement.Satisfied("1.69.0-beta.3")).To(BeFalse())
+		})
+	})
+
+	context("MSRVToolchain", func() {
+		it("returns the exact version", func() {
+			Expect(os.WriteFile(path, []byte("[package]\nname = \"app\"\nrust-version = \"1.70\"\n"), 0644)).To(Succeed())
+
+			Expect(rustup.MSRVToolchain(filepath.Dir(path))).To(Equal("1.70.0"))
+		})
+
+		it("fails without a rust-version", func() {
+			Expect(os.WriteFile(path, []byte("[package]\nname = \"app\"\n"), 0644)).To(Succeed())
+
+			_, err := rustup.MSRVToolchain(filepath.Dir(path))
+			Expect(err).To(MatchError(ContainSubstring("unable to find package.rust-version")))
+		})
+
+		it("fails on an invalid rust-version", func() {
+			Expect(os.WriteFile(path, []byte("[package]\nname = \"app\"\nrust-version = \"1.70-nightly\"\n"), 0644)).To(Succeed())
+
+			_, err := rustup.MSRVToolchain(filepath.Dir(path))
+			Expect(err).To(MatchError(ContainSubstring("must not have a pre-release")))
+		})
+	})
+}
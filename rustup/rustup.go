@@ -22,7 +22,6 @@ import (
 	"io"
 	"os"
 	"path/filepath"
-	"strings"
 
 	"github.com/buildpacks/libcnb"
 	"github.com/paketo-buildpacks/libpak"
@@ -39,6 +38,7 @@ type Rustup struct {
 	Logger           bard.Logger
 	Executor         effect.Executor
 	Profile          string
+	Mirror           Mirror
 }
 
 func NewRustup(rustupInitVersion string, profile string) Rustup {
@@ -69,6 +69,13 @@ func (r Rustup) Contribute(layer libcnb.Layer) (libcnb.Layer, error) {
 		return libcnb.Layer{}, fmt.Errorf("unable to set $RUSTUP_HOME\n%w", err)
 	}
 
+	if r.Mirror.IsSet() {
+		if err := r.Mirror.Setenv(); err != nil {
+			return libcnb.Layer{}, err
+		}
+		r.LayerContributor.ExpectedMetadata.(map[string]interface{})["mirror"] = r.Mirror.Metadata()
+	}
+
 	layer, err := r.LayerContributor.Contribute(layer, func() (libcnb.Layer, error) {
 		r.Logger.Body("Installing Rustup")
 
@@ -101,6 +108,9 @@ func (r Rustup) Contribute(layer libcnb.Layer) (libcnb.Layer, error) {
 		}
 
 		layer.BuildEnvironment.Override("RUSTUP_HOME", layer.Path)
+		for _, name := range r.Mirror.Override(layer.BuildEnvironment) {
+			r.Logger.Bodyf("%s is not set for subsequent buildpacks, as it contains credentials", name)
+		}
 
 		buf := &bytes.Buffer{}
 		if err := r.Executor.Execute(effect.Execution{
@@ -111,26 +121,36 @@ func (r Rustup) Contribute(layer libcnb.Layer) (libcnb.Layer, error) {
 		}); err != nil {
 			return libcnb.Layer{}, fmt.Errorf("error executing 'rustup --version':\n Combined Output: %s: \n%w", buf.String(), err)
 		}
-		ver := strings.Split(strings.TrimSpace(buf.String()), " ")
 
-		sbomPath := layer.SBOMPath(libcnb.SyftJSON)
-		dep := sbom.NewSyftDependency(layer.Path, []sbom.SyftArtifact{
+		ver, err := ParseRustupVersion(buf.String())
+		if err != nil {
+			return libcnb.Layer{}, fmt.Errorf("unable to parse rustup version\n%w", err)
+		}
+
+		artifacts := []SBOMArtifact{
 			{
-				ID:      "rustup",
-				Name:    "Rustup",
-				Version: ver[1],
-				Type:    "UnknownPackage",
-				FoundBy: "paketo-community/rustup",
-				Locations: []sbom.SyftLocation{
-					{Path: "paketo-community/rustup/rustup/rustup.go"},
+				SyftArtifact: sbom.SyftArtifact{
+					ID:      "rustup",
+					Name:    "Rustup",
+					Version: ver.Version,
+					Type:    "binary",
+					FoundBy: "paketo-community/rustup",
+					Locations: []sbom.SyftLocation{
+						{Path: "paketo-community/rustup/rustup/rustup.go"},
+					},
+					Licenses: []string{"Apache-2.0", "MIT"},
+					CPEs:     []string{fmt.Sprintf("cpe:2.3:a:rust-lang:rustup:%s:*:*:*:*:*:*:*", ver.Version)},
+					PURL: fmt.Sprintf("pkg:github/rust-lang/rustup@%s%s", ver.Version,
+						purlQualifiers(map[string]string{"commit": ver.CommitHash})),
+				},
+				Properties: map[string]string{
+					"rustup:commit-hash": ver.CommitHash,
+					"rustup:commit-date": ver.CommitDate,
 				},
-				Licenses: []string{"Apache-2.0", "MIT"},
-				CPEs:     []string{fmt.Sprintf("cpe:2.3:a:rustup:rustup:%s:*:*:*:*:*:*:*", ver[1])},
-				PURL:     fmt.Sprintf("pkg:generic/rustup@%s", ver[1]),
 			},
-		})
-		r.Logger.Debugf("Writing Syft SBOM at %s: %+v", sbomPath, dep)
-		if err := dep.WriteTo(sbomPath); err != nil {
+		}
+
+		if err := WriteSBOM(layer, r.Logger, artifacts); err != nil {
 			return libcnb.Layer{}, fmt.Errorf("unable to write SBOM\n%w", err)
 		}
 
@@ -0,0 +1,190 @@
+/*
+ * Copyright 2018-2024 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rustup
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/buildpacks/libcnb"
+	"github.com/paketo-buildpacks/libpak/bard"
+	"github.com/paketo-buildpacks/libpak/sbom"
+)
+
+// SBOMArtifact is an artifact written to both the Syft and the CycloneDX SBOM
+type SBOMArtifact struct {
+	sbom.SyftArtifact
+
+	// SHA256 is the hash of the artifact, empty if unknown. Syft has no field for it, so it is only written to CycloneDX.
+	SHA256 string
+
+	// Properties are additional details of the artifact, like the commit it was built from. Empty values are skipped.
+	// Syft has no field for them, so they are only written to CycloneDX.
+	Properties map[string]string
+}
+
+// CycloneDX is a minimal CycloneDX 1.4 document
+type CycloneDX struct {
+	BOMFormat   string               `json:"bomFormat"`
+	SpecVersion string               `json:"specVersion"`
+	Version     int                  `json:"version"`
+	Metadata    CycloneDXMetadata    `json:"metadata"`
+	Components  []CycloneDXComponent `json:"components"`
+}
+
+type CycloneDXMetadata struct {
+	Tools []CycloneDXTool `json:"tools"`
+}
+
+type CycloneDXTool struct {
+	Vendor string `json:"vendor"`
+	Name   string `json:"name"`
+}
+
+type CycloneDXComponent struct {
+	BOMRef     string              `json:"bom-ref"`
+	Type       string              `json:"type"`
+	Name       string              `json:"name"`
+	Version    string              `json:"version"`
+	Licenses   []CycloneDXLicense  `json:"licenses,omitempty"`
+	CPE        string              `json:"cpe,omitempty"`
+	PURL       string              `json:"purl,omitempty"`
+	Hashes     []CycloneDXHash     `json:"hashes,omitempty"`
+	Properties []CycloneDXProperty `json:"properties,omitempty"`
+}
+
+type CycloneDXLicense struct {
+	License CycloneDXLicenseID `json:"license"`
+}
+
+type CycloneDXLicenseID struct {
+	ID string `json:"id"`
+}
+
+type CycloneDXHash struct {
+	Algorithm string `json:"alg"`
+	Content   string `json:"content"`
+}
+
+type CycloneDXProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// NewCycloneDX converts artifacts to a CycloneDX document
+func NewCycloneDX(artifacts []SBOMArtifact) CycloneDX {
+	doc := CycloneDX{
+		BOMFormat:   "CycloneDX",
+		SpecVersion: "1.4",
+		Version:     1,
+		Metadata: CycloneDXMetadata{
+			Tools: []CycloneDXTool{{Vendor: "paketo-community", Name: "rustup"}},
+		},
+		Components: []CycloneDXComponent{},
+	}
+
+	for _, a := range artifacts {
+		c := CycloneDXComponent{
+			BOMRef:  a.ID,
+			Type:    "application",
+			Name:    a.Name,
+			Version: a.Version,
+			PURL:    a.PURL,
+		}
+
+		if a.SHA256 != "" {
+			c.Hashes = []CycloneDXHash{{Algorithm: "SHA-256", Content: a.SHA256}}
+		}
+
+		for _, l := range a.Licenses {
+			c.Licenses = append(c.Licenses, CycloneDXLicense{License: CycloneDXLicenseID{ID: l}})
+		}
+
+		// CycloneDX has a single CPE, additional CPEs are kept as properties like Syft does
+		for i, cpe := range a.CPEs {
+			if i == 0 {
+				c.CPE = cpe
+			} else {
+				c.Properties = append(c.Properties, CycloneDXProperty{Name: "syft:cpe23", Value: cpe})
+			}
+		}
+
+		for i, l := range a.Locations {
+			c.Properties = append(c.Properties, CycloneDXProperty{Name: fmt.Sprintf("syft:location:%d:path", i), Value: l.Path})
+		}
+
+		var names []string
+		for name, value := range a.Properties {
+			if value != "" {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			c.Properties = append(c.Properties, CycloneDXProperty{Name: name, Value: a.Properties[name]})
+		}
+
+		doc.Components = append(doc.Components, c)
+	}
+
+	return doc
+}
+
+// WriteTo writes the document as JSON
+func (c CycloneDX) WriteTo(path string) error {
+	raw, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("unable to marshal CycloneDX SBOM\n%w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("unable to create directory %s\n%w", filepath.Dir(path), err)
+	}
+
+	if err := os.WriteFile(path, raw, 0644); err != nil {
+		return fmt.Errorf("unable to write CycloneDX SBOM %s\n%w", path, err)
+	}
+
+	return nil
+}
+
+// WriteSBOM writes the Syft and CycloneDX SBOMs of a layer from the same artifacts
+func WriteSBOM(layer libcnb.Layer, logger bard.Logger, artifacts []SBOMArtifact) error {
+	var syftArtifacts []sbom.SyftArtifact
+	for _, a := range artifacts {
+		syftArtifacts = append(syftArtifacts, a.SyftArtifact)
+	}
+
+	syftPath := layer.SBOMPath(libcnb.SyftJSON)
+	dep := sbom.NewSyftDependency(layer.Path, syftArtifacts)
+	logger.Debugf("Writing Syft SBOM at %s: %+v", syftPath, dep)
+	if err := dep.WriteTo(syftPath); err != nil {
+		return fmt.Errorf("unable to write Syft SBOM\n%w", err)
+	}
+
+	cycloneDXPath := layer.SBOMPath(libcnb.CycloneDXJSON)
+	doc := NewCycloneDX(artifacts)
+	logger.Debugf("Writing CycloneDX SBOM at %s: %+v", cycloneDXPath, doc)
+	if err := doc.WriteTo(cycloneDXPath); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,122 @@
+/*
+ * Copyright 2018-2024 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rustup_test
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/buildpacks/libcnb"
+	. "github.com/onsi/gomega"
+	"github.com/paketo-buildpacks/libpak/bard"
+	"github.com/paketo-buildpacks/libpak/sbom"
+	"github.com/paketo-community/rustup/rustup"
+	"github.com/sclevine/spec"
+)
+
+func testSBOM(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		ctx libcnb.BuildContext
+
+		artifacts = []rustup.SBOMArtifact{
+			{
+				SyftArtifact: sbom.SyftArtifact{
+					ID:        "rust",
+					Name:      "Rust",
+					Version:   "1.78.0",
+					Type:      "UnknownPackage",
+					FoundBy:   "paketo-community/rustup",
+					Locations: []sbom.SyftLocation{{Path: "paketo-community/rustup/rustup/rust.go"}},
+					Licenses:  []string{"Apache-2.0", "MIT"},
+					CPEs:      []string{"cpe:2.3:a:rust:rust:1.78.0:*:*:*:*:*:*:*", "cpe:2.3:a:rust-lang:rust:1.78.0:*:*:*:*:*:*:*"},
+					PURL:      "pkg:generic/rust@1.78.0",
+				},
+			},
+		}
+	)
+
+	it.Before(func() {
+		var err error
+
+		ctx.Layers.Path, err = os.MkdirTemp("", "sbom-layers")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(ctx.Layers.Path)).To(Succeed())
+	})
+
+	it("converts artifacts to CycloneDX", func() {
+		doc := rustup.NewCycloneDX(artifacts)
+
+		Expect(doc.BOMFormat).To(Equal("CycloneDX"))
+		Expect(doc.Components).To(Equal([]rustup.CycloneDXComponent{
+			{
+				BOMRef:  "rust",
+				Type:    "application",
+				Name:    "Rust",
+				Version: "1.78.0",
+				Licenses: []rustup.CycloneDXLicense{
+					{License: rustup.CycloneDXLicenseID{ID: "Apache-2.0"}},
+					{License: rustup.CycloneDXLicenseID{ID: "MIT"}},
+				},
+				CPE:  "cpe:2.3:a:rust:rust:1.78.0:*:*:*:*:*:*:*",
+				PURL: "pkg:generic/rust@1.78.0",
+				Properties: []rustup.CycloneDXProperty{
+					{Name: "syft:cpe23", Value: "cpe:2.3:a:rust-lang:rust:1.78.0:*:*:*:*:*:*:*"},
+					{Name: "syft:location:0:path", Value: "paketo-community/rustup/rustup/rust.go"},
+				},
+			},
+		}))
+	})
+
+	it("includes the hash of an artifact", func() {
+		doc := rustup.NewCycloneDX([]rustup.SBOMArtifact{
+			{
+				SyftArtifact: sbom.SyftArtifact{ID: "rust-stable-rustc", Name: "rustc", Version: "1.78.0"},
+				SHA256:       "e59ce26bfc09de0c2d08192a3b4c5d6e7f8091a2b3c4d5e6f708192a3b4c5d6e",
+			},
+		})
+
+		Expect(doc.Components).To(HaveLen(1))
+		Expect(doc.Components[0].Hashes).To(Equal([]rustup.CycloneDXHash{
+			{Algorithm: "SHA-256", Content: "e59ce26bfc09de0c2d08192a3b4c5d6e7f8091a2b3c4d5e6f708192a3b4c5d6e"},
+		}))
+	})
+
+	it("writes both formats", func() {
+		layer, err := ctx.Layers.Layer("test-layer")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(rustup.WriteSBOM(layer, bard.NewLogger(os.Stdout), artifacts)).To(Succeed())
+
+		raw, err := os.ReadFile(layer.SBOMPath(libcnb.CycloneDXJSON))
+		Expect(err).NotTo(HaveOccurred())
+
+		var doc map[string]interface{}
+		Expect(json.Unmarshal(raw, &doc)).To(Succeed())
+		Expect(doc).To(HaveKeyWithValue("specVersion", "1.4"))
+		Expect(doc["components"]).To(HaveLen(1))
+
+		raw, err = os.ReadFile(layer.SBOMPath(libcnb.SyftJSON))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(raw)).To(ContainSubstring("pkg:generic/rust@1.78.0"))
+	})
+}
@@ -0,0 +1,112 @@
+/*
+ * Copyright 2018-2024 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rustup
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/paketo-buildpacks/libpak"
+)
+
+// TargetPlatform is the platform the application is built on. Platform API 0.12 and newer describe it with the
+// `CNB_TARGET_*` environment variables, on older platforms it is derived from the buildpack binary and the stack.
+type TargetPlatform struct {
+	// Arch is the architecture in Go notation, like `amd64` or `arm64`
+	Arch string
+
+	// ArchVariant is the variant of the architecture, like `v7` for `arm`
+	ArchVariant string
+
+	// DistroName is the name of the operating system distribution, like `ubuntu` or `alpine`
+	DistroName string
+
+	// DistroVersion is the version of the operating system distribution, like `22.04`
+	DistroVersion string
+
+	// Musl is true if binaries must link against musl, which is the case on Alpine, on the tiny and static stacks and
+	// on build images where rustup-init uses musl
+	Musl bool
+}
+
+// ResolveTargetPlatform reads the platform from `$CNB_TARGET_ARCH`, `$CNB_TARGET_ARCH_VARIANT`,
+// `$CNB_TARGET_DISTRO_NAME` and `$CNB_TARGET_DISTRO_VERSION`. musl is chosen for the Alpine distribution and if libc,
+// the libc rustup-init is installed for, is musl. On older platforms, which set neither `$CNB_TARGET_OS` nor
+// `$CNB_TARGET_ARCH`, the architecture of the buildpack binary is used and musl is also chosen for the tiny and static
+// stacks.
+func ResolveTargetPlatform(stack string, libc string) TargetPlatform {
+	p := TargetPlatform{
+		Arch:          os.Getenv("CNB_TARGET_ARCH"),
+		ArchVariant:   os.Getenv("CNB_TARGET_ARCH_VARIANT"),
+		DistroName:    strings.ToLower(os.Getenv("CNB_TARGET_DISTRO_NAME")),
+		DistroVersion: os.Getenv("CNB_TARGET_DISTRO_VERSION"),
+	}
+
+	legacy := p.Arch == "" && os.Getenv("CNB_TARGET_OS") == ""
+	if p.Arch == "" {
+		p.Arch, p.ArchVariant = runtime.GOARCH, ""
+	}
+
+	p.Musl = libc == LibcMusl || p.DistroName == "alpine" ||
+		(legacy && (libpak.IsTinyStack(stack) || libpak.IsStaticStack(stack)))
+
+	return p
+}
+
+// HostTriple returns the Rust target triple of the platform, like `x86_64-unknown-linux-gnu`
+func (p TargetPlatform) HostTriple() (string, error) {
+	libc := "gnu"
+	if p.Musl {
+		libc = "musl"
+	}
+
+	switch p.Arch {
+	case "amd64":
+		return fmt.Sprintf("x86_64-unknown-linux-%s", libc), nil
+	case "arm64":
+		return fmt.Sprintf("aarch64-unknown-linux-%s", libc), nil
+	case "arm":
+		// 32-bit ARM targets use the hard float ABI, v6 is the oldest variant Rust supports on Linux
+		switch p.ArchVariant {
+		case "", "v7":
+			return fmt.Sprintf("armv7-unknown-linux-%seabihf", libc), nil
+		case "v6":
+			return fmt.Sprintf("arm-unknown-linux-%seabihf", libc), nil
+		}
+	case "ppc64le":
+		return fmt.Sprintf("powerpc64le-unknown-linux-%s", libc), nil
+	case "s390x":
+		return fmt.Sprintf("s390x-unknown-linux-%s", libc), nil
+	case "riscv64":
+		return fmt.Sprintf("riscv64gc-unknown-linux-%s", libc), nil
+	}
+
+	return "", fmt.Errorf("unsupported architecture %s", p)
+}
+
+func (p TargetPlatform) String() string {
+	s := p.Arch
+	if p.ArchVariant != "" {
+		s = fmt.Sprintf("%s/%s", s, p.ArchVariant)
+	}
+	if p.DistroName != "" {
+		s = fmt.Sprintf("%s (%s)", s, strings.TrimSpace(p.DistroName+" "+p.DistroVersion))
+	}
+	return s
+}
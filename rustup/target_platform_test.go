@@ -0,0 +1,126 @@
+/*
+ * Copyright 2018-2024 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rustup_test
+
+import (
+	"os"
+	"runtime"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/paketo-buildpacks/libpak"
+	"github.com/paketo-community/rustup/rustup"
+	"github.com/sclevine/spec"
+)
+
+func testTargetPlatform(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+	)
+
+	context("ResolveTargetPlatform", func() {
+		it.After(func() {
+			Expect(os.Unsetenv("CNB_TARGET_OS")).To(Succeed())
+			Expect(os.Unsetenv("CNB_TARGET_ARCH")).To(Succeed())
+			Expect(os.Unsetenv("CNB_TARGET_ARCH_VARIANT")).To(Succeed())
+			Expect(os.Unsetenv("CNB_TARGET_DISTRO_NAME")).To(Succeed())
+			Expect(os.Unsetenv("CNB_TARGET_DISTRO_VERSION")).To(Succeed())
+		})
+
+		it("reads the target environment", func() {
+			Expect(os.Setenv("CNB_TARGET_ARCH", "arm")).To(Succeed())
+			Expect(os.Setenv("CNB_TARGET_ARCH_VARIANT", "v6")).To(Succeed())
+			Expect(os.Setenv("CNB_TARGET_DISTRO_NAME", "ubuntu")).To(Succeed())
+			Expect(os.Setenv("CNB_TARGET_DISTRO_VERSION", "22.04")).To(Succeed())
+
+			p := rustup.ResolveTargetPlatform("", "")
+			Expect(p).To(Equal(rustup.TargetPlatform{
+				Arch:          "arm",
+				ArchVariant:   "v6",
+				DistroName:    "ubuntu",
+				DistroVersion: "22.04",
+			}))
+			Expect(p.String()).To(Equal("arm/v6 (ubuntu 22.04)"))
+		})
+
+		it("picks musl on alpine", func() {
+			Expect(os.Setenv("CNB_TARGET_ARCH", "arm64")).To(Succeed())
+			Expect(os.Setenv("CNB_TARGET_DISTRO_NAME", "Alpine")).To(Succeed())
+
+			Expect(rustup.ResolveTargetPlatform("", "").Musl).To(BeTrue())
+		})
+
+		it("ignores a legacy stack ID when the target environment is set", func() {
+			Expect(os.Setenv("CNB_TARGET_ARCH", "amd64")).To(Succeed())
+			Expect(os.Setenv("CNB_TARGET_DISTRO_NAME", "ubuntu")).To(Succeed())
+
+			Expect(rustup.ResolveTargetPlatform(libpak.JammyStaticStackID, "").Musl).To(BeFalse())
+			Expect(rustup.ResolveTargetPlatform(libpak.BionicTinyStackID, "").Musl).To(BeFalse())
+		})
+
+		it("ignores a legacy stack ID when only the target OS is set", func() {
+			Expect(os.Setenv("CNB_TARGET_OS", "linux")).To(Succeed())
+
+			Expect(rustup.ResolveTargetPlatform(libpak.JammyStaticStackID, "").Musl).To(BeFalse())
+		})
+
+		it("picks musl when rustup-init uses musl", func() {
+			Expect(rustup.ResolveTargetPlatform(libpak.BionicStackID, "musl").Musl).To(BeTrue())
+			Expect(rustup.ResolveTargetPlatform(libpak.BionicStackID, "gnu").Musl).To(BeFalse())
+		})
+
+		it("falls back to the buildpack architecture and stack on older platforms", func() {
+			Expect(os.Setenv("CNB_TARGET_ARCH_VARIANT", "v7")).To(Succeed())
+
+			p := rustup.ResolveTargetPlatform(libpak.BionicTinyStackID, "")
+			Expect(p.Arch).To(Equal(runtime.GOARCH))
+			Expect(p.ArchVariant).To(BeEmpty())
+			Expect(p.Musl).To(BeTrue())
+
+			Expect(rustup.ResolveTargetPlatform(libpak.BionicStackID, "").Musl).To(BeFalse())
+		})
+	})
+
+	context("HostTriple", func() {
+		it("maps architectures to target triples", func() {
+			for p, triple := range map[rustup.TargetPlatform]string{
+				{Arch: "amd64"}:                              "x86_64-unknown-linux-gnu",
+				{Arch: "amd64", Musl: true}:                  "x86_64-unknown-linux-musl",
+				{Arch: "arm64", ArchVariant: "v8"}:           "aarch64-unknown-linux-gnu",
+				{Arch: "arm"}:                                "armv7-unknown-linux-gnueabihf",
+				{Arch: "arm", ArchVariant: "v7"}:             "armv7-unknown-linux-gnueabihf",
+				{Arch: "arm", ArchVariant: "v6", Musl: true}: "arm-unknown-linux-musleabihf",
+				{Arch: "ppc64le"}:                            "powerpc64le-unknown-linux-gnu",
+				{Arch: "s390x"}:                              "s390x-unknown-linux-gnu",
+				{Arch: "riscv64"}:                            "riscv64gc-unknown-linux-gnu",
+				{Arch: "riscv64", Musl: true}:                "riscv64gc-unknown-linux-musl",
+			} {
+				host, err := p.HostTriple()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(host).To(Equal(triple), p.String())
+			}
+		})
+
+		it("fails for an unsupported architecture", func() {
+			_, err := rustup.TargetPlatform{Arch: "mips64le"}.HostTriple()
+			Expect(err).To(MatchError("unsupported architecture mips64le"))
+
+			_, err = rustup.TargetPlatform{Arch: "arm", ArchVariant: "v5"}.HostTriple()
+			Expect(err).To(MatchError("unsupported architecture arm/v5"))
+		})
+	})
+}
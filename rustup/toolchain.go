@@ -0,0 +1,203 @@
+/*
+ * Copyright 2018-2024 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rustup
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Toolchain is a single toolchain to be installed by rustup
+type Toolchain struct {
+	// Name is the channel to install, like `stable`, `1.78.0` or `nightly-2024-05-01`, or the name a custom toolchain
+	// is linked as
+	Name string
+
+	// Path is the location of a custom toolchain to link, empty for toolchains installed from a channel
+	Path string
+
+	// Profile is the profile to install
+	Profile string
+
+	// Components are additional components to install
+	Components []string
+
+	// Targets are additional targets to install
+	Targets []string
+
+	// Pinned is the exact channel to install in place of Name, like `1.78.0` for `stable`, empty if not pinned
+	Pinned string
+
+	// Range is the version range Name was resolved from, like `1.78.*`, empty if Name was given literally
+	Range string
+}
+
+// IsLinked returns true if the toolchain is a custom toolchain that is linked rather than installed
+func (t Toolchain) IsLinked() bool {
+	return t.Path != ""
+}
+
+// Channel returns the channel rustup installs, which is the pinned channel if set
+func (t Toolchain) Channel() string {
+	if t.Pinned != "" {
+		return t.Pinned
+	}
+	return t.Name
+}
+
+// LockName returns the name the toolchain is recorded with in the lock file. Toolchains resolved from a version range
+// are recorded with the range, so that the lock still applies once a newer release matches the range.
+func (t Toolchain) LockName() string {
+	if t.Range != "" {
+		return t.Range
+	}
+	return t.Name
+}
+
+// Metadata returns a normalized representation of the toolchain for layer metadata
+func (t Toolchain) Metadata() map[string]interface{} {
+	m := map[string]interface{}{
+		"name":       t.Name,
+		"path":       t.Path,
+		"profile":    t.Profile,
+		"components": sorted(t.Components),
+		"targets":    sorted(t.Targets),
+	}
+	if t.Pinned != "" {
+		m["pinned"] = t.Pinned
+	}
+	if t.Range != "" {
+		m["range"] = t.Range
+	}
+	return m
+}
+
+func (t Toolchain) String() string {
+	s := []string{t.Name}
+	if t.Range != "" {
+		s = append(s, fmt.Sprintf("range=%s", t.Range))
+	}
+	if t.Pinned != "" {
+		s = append(s, fmt.Sprintf("pinned=%s", t.Pinned))
+	}
+	if t.IsLinked() {
+		s = append(s, fmt.Sprintf("path=%s", t.Path))
+	} else {
+		s = append(s, fmt.Sprintf("profile=%s", t.Profile))
+	}
+	if len(t.Components) > 0 {
+		s = append(s, fmt.Sprintf("components=%s", strings.Join(t.Components, ",")))
+	}
+	if len(t.Targets) > 0 {
+		s = append(s, fmt.Sprintf("targets=%s", strings.Join(t.Targets, ",")))
+	}
+	return strings.Join(s, " ")
+}
+
+// Merge combines the components and targets of two specifications of the same toolchain, keeping the more complete
+// of the two profiles
+func (t Toolchain) Merge(other Toolchain) Toolchain {
+	t.Components = append([]string{}, t.Components...)
+	t.Targets = append([]string{}, t.Targets...)
+
+	for _, c := range other.Components {
+		if !contains(t.Components, c) {
+			t.Components = append(t.Components, c)
+		}
+	}
+
+	for _, c := range other.Targets {
+		if !contains(t.Targets, c) {
+			t.Targets = append(t.Targets, c)
+		}
+	}
+
+	if profileIndex(other.Profile) > profileIndex(t.Profile) {
+		t.Profile = other.Profile
+	}
+
+	return t
+}
+
+// AppendToolchain adds a toolchain to a list of toolchains, merging it into an existing entry with the same name
+func AppendToolchain(toolchains []Toolchain, toolchain Toolchain) []Toolchain {
+	for i, t := range toolchains {
+		if t.Name == toolchain.Name {
+			toolchains[i] = t.Merge(toolchain)
+			return toolchains
+		}
+	}
+
+	return append(toolchains, toolchain)
+}
+
+// ParseAdditionalToolchains parses a space separated list of toolchains. Each toolchain is a channel followed by
+// optional `;`-separated settings, for example `nightly-2024-05-01;components=miri,rust-src;targets=wasm32-wasip1`.
+// Toolchains that do not set a profile use the given default profile.
+func ParseAdditionalToolchains(val string, profile string) ([]Toolchain, error) {
+	var toolchains []Toolchain
+
+	for _, entry := range strings.FieldsFunc(val, unicode.IsSpace) {
+		parts := strings.Split(entry, ";")
+
+		t := Toolchain{Name: parts[0], Profile: profile}
+		if t.Name == "" {
+			return nil, fmt.Errorf("invalid toolchain %q, the channel must be set", entry)
+		}
+
+		for _, part := range parts[1:] {
+			key, value, ok := strings.Cut(part, "=")
+			if !ok {
+				return nil, fmt.Errorf("invalid toolchain %q, expected key=value but got %q", entry, part)
+			}
+
+			var err error
+			switch key {
+			case "components":
+				if t.Components, err = ParseComponents(value); err != nil {
+					return nil, fmt.Errorf("invalid toolchain %q\n%w", entry, err)
+				}
+			case "targets":
+				if t.Targets, err = ParseTargets(value); err != nil {
+					return nil, fmt.Errorf("invalid toolchain %q\n%w", entry, err)
+				}
+			case "profile":
+				if !contains(validProfiles, value) {
+					return nil, fmt.Errorf("invalid toolchain %q, profile must be one of %s",
+						entry, strings.Join(validProfiles, ", "))
+				}
+				t.Profile = value
+			default:
+				return nil, fmt.Errorf("invalid toolchain %q, unknown key %q", entry, key)
+			}
+		}
+
+		toolchains = AppendToolchain(toolchains, t)
+	}
+
+	return toolchains, nil
+}
+
+func profileIndex(profile string) int {
+	for i, p := range validProfiles {
+		if p == profile {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,393 @@
+/*
+ * Copyright 2018-2024 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rustup
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// LocalToolchainName is the name a toolchain configured with `path` is linked as
+const LocalToolchainName = "local"
+
+var validProfiles = []string{"minimal", "default", "complete"}
+
+// ToolchainFileNames are the files in the application a toolchain is read from, in order of precedence
+var ToolchainFileNames = []string{"rust-toolchain", "rust-toolchain.toml", "mise.toml", ".mise.toml", ".tool-versions"}
+
+// ToolchainFile is the parsed content of a `rust-toolchain`, `rust-toolchain.toml`, `mise.toml` or `.tool-versions`
+// file
+type ToolchainFile struct {
+	// Path is the location of the toolchain file, empty if there is no toolchain file
+	Path string
+
+	// Channel is the toolchain channel, like `stable`, `1.78.0` or `nightly-2024-05-01`
+	Channel string
+
+	// Components are additional components to install
+	Components []string
+
+	// Targets are additional targets to install
+	Targets []string
+
+	// Profile is the profile to install, empty if not set
+	Profile string
+
+	// ToolchainPath is the absolute path of a custom toolchain, mutually exclusive with Channel
+	ToolchainPath string
+}
+
+type toolchainFileContent struct {
+	Toolchain struct {
+		Channel    string   `toml:"channel"`
+		Components []string `toml:"components"`
+		Targets    []string `toml:"targets"`
+		Profile    string   `toml:"profile"`
+		Path       string   `toml:"path"`
+	} `toml:"toolchain"`
+}
+
+// ReadToolchainFiles parses the toolchain files of an application in order of precedence. Files that do not request
+// Rust, like a `.tool-versions` for other tools, are skipped.
+func ReadToolchainFiles(appPath string) ([]ToolchainFile, error) {
+	var files []ToolchainFile
+
+	for _, name := range ToolchainFileNames {
+		path := filepath.Join(appPath, name)
+		if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("unable to stat %s\n%w", path, err)
+		}
+
+		t, err := ParseToolchainFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if t.Exists() {
+			files = append(files, t)
+		}
+	}
+
+	return files, nil
+}
+
+// ParseToolchainFile reads a toolchain file. `rust-toolchain` files are in either the legacy single line format or
+// the TOML format, `mise.toml` and `.tool-versions` files are read for their `rust` tool. An empty path, or a file
+// that does not request Rust, returns an empty ToolchainFile.
+func ParseToolchainFile(path string) (ToolchainFile, error) {
+	if path == "" {
+		return ToolchainFile{}, nil
+	}
+
+	switch filepath.Base(path) {
+	case "mise.toml", ".mise.toml":
+		return parseMiseToml(path)
+	case ".tool-versions":
+		return parseToolVersions(path)
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return ToolchainFile{}, fmt.Errorf("unable to read %s\n%w", path, err)
+	}
+
+	content := strings.TrimSpace(string(raw))
+	if content == "" {
+		return ToolchainFile{}, fmt.Errorf("%s is empty", path)
+	}
+
+	// the legacy format is a single line containing only a channel name, anything else must be TOML
+	if !strings.ContainsAny(content, "\n=[") {
+		if strings.ContainsAny(content, " \t") {
+			return ToolchainFile{}, fmt.Errorf("%s has an invalid channel %q", path, content)
+		}
+		return ToolchainFile{Path: path, Channel: content}, nil
+	}
+
+	var c toolchainFileContent
+	md, err := toml.Decode(content, &c)
+	if err != nil {
+		return ToolchainFile{}, fmt.Errorf("unable to parse %s\n%w", path, err)
+	}
+
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		var keys []string
+		for _, k := range undecoded {
+			keys = append(keys, k.String())
+		}
+		return ToolchainFile{}, fmt.Errorf("%s has unknown keys: %s", path, strings.Join(keys, ", "))
+	}
+
+	if !md.IsDefined("toolchain") {
+		return ToolchainFile{}, fmt.Errorf("%s is missing the [toolchain] section", path)
+	}
+
+	t := ToolchainFile{
+		Path:       path,
+		Channel:    strings.TrimSpace(c.Toolchain.Channel),
+		Components: c.Toolchain.Components,
+		Targets:    c.Toolchain.Targets,
+		Profile:    c.Toolchain.Profile,
+	}
+
+	if c.Toolchain.Path != "" {
+		if t.Channel != "" {
+			return ToolchainFile{}, fmt.Errorf("%s must not set both channel and path", path)
+		}
+		if len(t.Components) > 0 || len(t.Targets) > 0 || t.Profile != "" {
+			return ToolchainFile{}, fmt.Errorf("%s must not set components, targets or profile with path", path)
+		}
+
+		t.ToolchainPath = c.Toolchain.Path
+		if !filepath.IsAbs(t.ToolchainPath) {
+			t.ToolchainPath = filepath.Join(filepath.Dir(path), t.ToolchainPath)
+		}
+	}
+
+	if err := t.validate(); err != nil {
+		return ToolchainFile{}, err
+	}
+
+	return t, nil
+}
+
+// validate checks the channel, profile, components and targets requested by the file
+func (t ToolchainFile) validate() error {
+	if strings.ContainsAny(t.Channel, " \t") {
+		return fmt.Errorf("%s has an invalid channel %q", t.Path, t.Channel)
+	}
+
+	if t.Profile != "" && !contains(validProfiles, t.Profile) {
+		return fmt.Errorf("%s has an invalid profile %q, must be one of %s",
+			t.Path, t.Profile, strings.Join(validProfiles, ", "))
+	}
+
+	for _, component := range t.Components {
+		if component == "" || strings.ContainsAny(component, " \t") {
+			return fmt.Errorf("%s has an invalid component %q", t.Path, component)
+		}
+	}
+
+	for _, target := range t.Targets {
+		if target == "" || strings.ContainsAny(target, " \t") {
+			return fmt.Errorf("%s has an invalid target %q", t.Path, target)
+		}
+	}
+
+	return nil
+}
+
+// toolVersionChannel converts a Rust version of asdf or mise to a rustup channel. `system` means the tool is not
+// managed, so it returns false. Version syntaxes of asdf and mise with a `:`, like `ref:`, `path:` or `prefix:`, have no
+// rustup channel and are rejected.
+func toolVersionChannel(path string, version string) (string, bool, error) {
+	switch version {
+	case "", "system":
+		return "", false, nil
+	case "latest":
+		return "stable", true, nil
+	}
+
+	if strings.Contains(version, ":") {
+		return "", false, fmt.Errorf("%s has an unsupported rust version %q, must be a version, a channel, latest or system",
+			path, version)
+	}
+
+	return version, true, nil
+}
+
+// parseToolVersions reads the first version of the `rust` tool in an asdf `.tool-versions` file
+func parseToolVersions(path string) (ToolchainFile, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return ToolchainFile{}, fmt.Errorf("unable to read %s\n%w", path, err)
+	}
+
+	for _, line := range strings.Split(string(raw), "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 || fields[0] != "rust" {
+			continue
+		}
+		if len(fields) < 2 {
+			return ToolchainFile{}, fmt.Errorf("%s has no version for rust", path)
+		}
+
+		channel, ok, err := toolVersionChannel(path, fields[1])
+		if err != nil {
+			return ToolchainFile{}, err
+		}
+		if !ok {
+			return ToolchainFile{}, nil
+		}
+
+		t := ToolchainFile{Path: path, Channel: channel}
+		return t, t.validate()
+	}
+
+	return ToolchainFile{}, nil
+}
+
+type miseContent struct {
+	Tools map[string]interface{} `toml:"tools"`
+}
+
+// parseMiseToml reads the `rust` tool in the `[tools]` section of a `mise.toml` file. The tool is a version, a list
+// of versions of which the first is used, or a table with `version` and the `profile`, `components` and `targets`
+// options of mise.
+func parseMiseToml(path string) (ToolchainFile, error) {
+	var c miseContent
+	if _, err := toml.DecodeFile(path, &c); err != nil {
+		return ToolchainFile{}, fmt.Errorf("unable to parse %s\n%w", path, err)
+	}
+
+	tool, ok := c.Tools["rust"]
+	if !ok {
+		return ToolchainFile{}, nil
+	}
+	if versions, ok := tool.([]interface{}); ok {
+		if len(versions) == 0 {
+			return ToolchainFile{}, fmt.Errorf("%s has no version for rust", path)
+		}
+		tool = versions[0]
+	}
+
+	t := ToolchainFile{Path: path}
+	var version string
+	switch v := tool.(type) {
+	case string:
+		version = v
+	case map[string]interface{}:
+		for key, value := range v {
+			s, ok := value.(string)
+			if !ok {
+				return ToolchainFile{}, fmt.Errorf("%s has an invalid rust option %s, must be a string", path, key)
+			}
+
+			switch key {
+			case "version":
+				version = s
+			case "profile":
+				t.Profile = s
+			case "components":
+				t.Components = splitList(s)
+			case "targets":
+				t.Targets = splitList(s)
+			}
+		}
+	default:
+		return ToolchainFile{}, fmt.Errorf("%s has an invalid rust tool, must be a version or a table", path)
+	}
+
+	var err error
+	if t.Channel, ok, err = toolVersionChannel(path, strings.TrimSpace(version)); err != nil {
+		return ToolchainFile{}, err
+	} else if !ok {
+		return ToolchainFile{}, nil
+	}
+
+	return t, t.validate()
+}
+
+// Exists returns true if a toolchain file was found
+func (t ToolchainFile) Exists() bool {
+	return t.Path != ""
+}
+
+// IsRustupFile returns true for `rust-toolchain` and `rust-toolchain.toml`, which rustup itself follows when cargo runs
+// in the application
+func (t ToolchainFile) IsRustupFile() bool {
+	name := filepath.Base(t.Path)
+	return t.Exists() && (name == "rust-toolchain" || name == "rust-toolchain.toml")
+}
+
+// Name returns the name of the toolchain rustup knows this toolchain by
+func (t ToolchainFile) Name() string {
+	if t.ToolchainPath != "" {
+		return LocalToolchainName
+	}
+	return t.Channel
+}
+
+// Toolchain returns the toolchain requested by the file. The given profile is used if the file does not set one, and
+// the given channel is used if the file sets neither a channel nor a path.
+func (t ToolchainFile) Toolchain(profile string, channel string) Toolchain {
+	toolchain := Toolchain{
+		Name:       t.Name(),
+		Path:       t.ToolchainPath,
+		Profile:    t.Profile,
+		Components: t.Components,
+		Targets:    t.Targets,
+	}
+
+	if toolchain.Name == "" {
+		toolchain.Name = channel
+	}
+
+	if toolchain.Profile == "" && !toolchain.IsLinked() {
+		toolchain.Profile = profile
+	}
+
+	return toolchain
+}
+
+func (t ToolchainFile) String() string {
+	var s []string
+	if t.Channel != "" {
+		s = append(s, fmt.Sprintf("channel=%s", t.Channel))
+	}
+	if t.ToolchainPath != "" {
+		s = append(s, fmt.Sprintf("path=%s", t.ToolchainPath))
+	}
+	if t.Profile != "" {
+		s = append(s, fmt.Sprintf("profile=%s", t.Profile))
+	}
+	if len(t.Components) > 0 {
+		s = append(s, fmt.Sprintf("components=%s", strings.Join(t.Components, ",")))
+	}
+	if len(t.Targets) > 0 {
+		s = append(s, fmt.Sprintf("targets=%s", strings.Join(t.Targets, ",")))
+	}
+	return strings.Join(s, " ")
+}
+
+func contains(candidates []string, value string) bool {
+	for _, c := range candidates {
+		if c == value {
+			return true
+		}
+	}
+	return false
+}
+
+func sorted(values []string) []string {
+	s := append([]string{}, values...)
+	sort.Strings(s)
+	return s
+}
@@ -0,0 +1,301 @@
+/*
+ * Copyright 2018-2024 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rustup_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/paketo-community/rustup/rustup"
+	"github.com/sclevine/spec"
+)
+
+func testToolchainFile(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		appPath string
+	)
+
+	it.Before(func() {
+		var err error
+
+		appPath, err = os.MkdirTemp("", "toolchain-file")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(appPath)).To(Succeed())
+	})
+
+	write := func(name string, content string) string {
+		path := filepath.Join(appPath, name)
+		Expect(os.WriteFile(path, []byte(content), 0644)).To(Succeed())
+		return path
+	}
+
+	it("returns an empty toolchain file without a path", func() {
+		t, err := rustup.ParseToolchainFile("")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(t.Exists()).To(BeFalse())
+	})
+
+	it("parses the legacy format", func() {
+		path := write("rust-toolchain", "nightly-2024-05-01\n")
+
+		t, err := rustup.ParseToolchainFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(t).To(Equal(rustup.ToolchainFile{Path: path, Channel: "nightly-2024-05-01"}))
+		Expect(t.Name()).To(Equal("nightly-2024-05-01"))
+	})
+
+	it("parses the TOML format", func() {
+		path := write("rust-toolchain.toml", `# pinned for the release
+[toolchain]
+channel = "1.78.0"
+components = ["rustfmt", "clippy"]
+targets = ["wasm32-unknown-unknown"]
+profile = "minimal"
+`)
+
+		t, err := rustup.ParseToolchainFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(t).To(Equal(rustup.ToolchainFile{
+			Path:       path,
+			Channel:    "1.78.0",
+			Components: []string{"rustfmt", "clippy"},
+			Targets:    []string{"wasm32-unknown-unknown"},
+			Profile:    "minimal",
+		}))
+		Expect(t.Toolchain("default", "stable")).To(Equal(rustup.Toolchain{
+			Name:       "1.78.0",
+			Profile:    "minimal",
+			Components: []string{"rustfmt", "clippy"},
+			Targets:    []string{"wasm32-unknown-unknown"},
+		}))
+	})
+
+	it("uses the given channel and profile if the file does not set them", func() {
+		path := write("rust-toolchain.toml", "[toolchain]\ncomponents = [\"clippy\"]\n")
+
+		t, err := rustup.ParseToolchainFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(t.Toolchain("minimal", "1.2.3")).To(Equal(rustup.Toolchain{
+			Name:       "1.2.3",
+			Profile:    "minimal",
+			Components: []string{"clippy"},
+		}))
+	})
+
+	it("parses the TOML format in a legacy file name", func() {
+		path := write("rust-toolchain", "[toolchain]\nchannel = \"beta\"\n")
+
+		t, err := rustup.ParseToolchainFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(t.Channel).To(Equal("beta"))
+	})
+
+	it("resolves a relative toolchain path", func() {
+		path := write("rust-toolchain.toml", "[toolchain]\npath = \"custom\"\n")
+
+		t, err := rustup.ParseToolchainFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(t.ToolchainPath).To(Equal(filepath.Join(appPath, "custom")))
+		Expect(t.Name()).To(Equal(rustup.LocalToolchainName))
+		Expect(t.Toolchain("minimal", "stable")).To(Equal(rustup.Toolchain{
+			Name: rustup.LocalToolchainName,
+			Path: filepath.Join(appPath, "custom"),
+		}))
+	})
+
+	it("does not change metadata when formatting changes", func() {
+		a, err := rustup.ParseToolchainFile(write("a.toml", "[toolchain]\nchannel = \"stable\"\ncomponents = [\"clippy\", \"rustfmt\"]\n"))
+		Expect(err).NotTo(HaveOccurred())
+
+		b, err := rustup.ParseToolchainFile(write("b.toml", "# comment\n[toolchain]\ncomponents = [\n  \"rustfmt\",\n  \"clippy\",\n]\nchannel = \"stable\"\n"))
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(a.Toolchain("minimal", "stable").Metadata()).To(Equal(b.Toolchain("minimal", "stable").Metadata()))
+	})
+
+	context("invalid files", func() {
+		it("rejects an empty file", func() {
+			_, err := rustup.ParseToolchainFile(write("rust-toolchain", "\n"))
+			Expect(err).To(MatchError(ContainSubstring("is empty")))
+		})
+
+		it("rejects a legacy channel with whitespace", func() {
+			_, err := rustup.ParseToolchainFile(write("rust-toolchain", "stable nightly"))
+			Expect(err).To(MatchError(ContainSubstring("invalid channel")))
+		})
+
+		it("rejects invalid TOML", func() {
+			_, err := rustup.ParseToolchainFile(write("rust-toolchain.toml", "[toolchain\nchannel = stable"))
+			Expect(err).To(MatchError(ContainSubstring("unable to parse")))
+		})
+
+		it("rejects a missing toolchain section", func() {
+			_, err := rustup.ParseToolchainFile(write("rust-toolchain.toml", "channel = \"stable\"\n"))
+			Expect(err).To(MatchError(ContainSubstring("unknown keys: channel")))
+		})
+
+		it("rejects unknown keys", func() {
+			_, err := rustup.ParseToolchainFile(write("rust-toolchain.toml", "[toolchain]\nchanel = \"stable\"\n"))
+			Expect(err).To(MatchError(ContainSubstring("unknown keys: toolchain.chanel")))
+		})
+
+		it("rejects channel and path", func() {
+			_, err := rustup.ParseToolchainFile(write("rust-toolchain.toml", "[toolchain]\nchannel = \"stable\"\npath = \"/opt\"\n"))
+			Expect(err).To(MatchError(ContainSubstring("must not set both channel and path")))
+		})
+
+		it("rejects components with path", func() {
+			_, err := rustup.ParseToolchainFile(write("rust-toolchain.toml", "[toolchain]\npath = \"/opt\"\ncomponents = [\"clippy\"]\n"))
+			Expect(err).To(MatchError(ContainSubstring("must not set components, targets or profile with path")))
+		})
+
+		it("rejects an invalid profile", func() {
+			_, err := rustup.ParseToolchainFile(write("rust-toolchain.toml", "[toolchain]\nchannel = \"stable\"\nprofile = \"huge\"\n"))
+			Expect(err).To(MatchError(ContainSubstring("invalid profile \"huge\"")))
+		})
+	})
+
+	context(".tool-versions", func() {
+		it("reads the first rust version", func() {
+			path := write(".tool-versions", "nodejs 20.11.0\n# pinned for CI\nrust 1.78.0 1.77.2 # current\n")
+
+			t, err := rustup.ParseToolchainFile(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(t).To(Equal(rustup.ToolchainFile{Path: path, Channel: "1.78.0"}))
+		})
+
+		it("installs stable for latest", func() {
+			t, err := rustup.ParseToolchainFile(write(".tool-versions", "rust latest\n"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(t.Channel).To(Equal("stable"))
+		})
+
+		it("skips a file without rust", func() {
+			t, err := rustup.ParseToolchainFile(write(".tool-versions", "nodejs 20.11.0\n"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(t.Exists()).To(BeFalse())
+		})
+
+		it("skips the system rust", func() {
+			t, err := rustup.ParseToolchainFile(write(".tool-versions", "rust system\n"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(t.Exists()).To(BeFalse())
+		})
+
+		it("rejects rust without a version", func() {
+			_, err := rustup.ParseToolchainFile(write(".tool-versions", "rust\n"))
+			Expect(err).To(MatchError(ContainSubstring("has no version for rust")))
+		})
+
+		it("rejects unsupported version syntaxes", func() {
+			for _, version := range []string{"ref:abc123", "path:/opt/rust", "prefix:1.78"} {
+				path := write(".tool-versions", "rust "+version+"\n")
+
+				_, err := rustup.ParseToolchainFile(path)
+				Expect(err).To(MatchError(path + " has an unsupported rust version \"" + version +
+					"\", must be a version, a channel, latest or system"))
+			}
+		})
+	})
+
+	context("mise.toml", func() {
+		it("reads a version", func() {
+			path := write("mise.toml", "[tools]\nnode = \"20\"\nrust = \"1.78.0\"\n")
+
+			t, err := rustup.ParseToolchainFile(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(t).To(Equal(rustup.ToolchainFile{Path: path, Channel: "1.78.0"}))
+		})
+
+		it("reads the first of a list of versions", func() {
+			t, err := rustup.ParseToolchainFile(write(".mise.toml", "[tools]\nrust = [\"nightly\", \"stable\"]\n"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(t.Channel).To(Equal("nightly"))
+		})
+
+		it("reads the rust options", func() {
+			path := write("mise.toml", `[tools]
+rust = { version = "1.78", profile = "default", components = "clippy,rustfmt", targets = "wasm32-unknown-unknown" }
+`)
+
+			t, err := rustup.ParseToolchainFile(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(t).To(Equal(rustup.ToolchainFile{
+				Path:       path,
+				Channel:    "1.78",
+				Profile:    "default",
+				Components: []string{"clippy", "rustfmt"},
+				Targets:    []string{"wasm32-unknown-unknown"},
+			}))
+		})
+
+		it("skips a file without rust", func() {
+			t, err := rustup.ParseToolchainFile(write("mise.toml", "[env]\nRUST_LOG = \"debug\"\n"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(t.Exists()).To(BeFalse())
+		})
+
+		it("rejects an invalid profile", func() {
+			_, err := rustup.ParseToolchainFile(write("mise.toml", "[tools]\nrust = { version = \"stable\", profile = \"huge\" }\n"))
+			Expect(err).To(MatchError(ContainSubstring("invalid profile")))
+		})
+
+		it("rejects unsupported version syntaxes", func() {
+			_, err := rustup.ParseToolchainFile(write("mise.toml", "[tools]\nrust = \"sub-1:latest\"\n"))
+			Expect(err).To(MatchError(ContainSubstring("has an unsupported rust version \"sub-1:latest\"")))
+
+			_, err = rustup.ParseToolchainFile(write("mise.toml", "[tools]\nrust = { version = \"ref:abc123\" }\n"))
+			Expect(err).To(MatchError(ContainSubstring("has an unsupported rust version \"ref:abc123\"")))
+		})
+	})
+
+	context("ReadToolchainFiles", func() {
+		it("returns nothing without toolchain files", func() {
+			files, err := rustup.ReadToolchainFiles(appPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(files).To(BeEmpty())
+		})
+
+		it("returns the files in order of precedence", func() {
+			write(".tool-versions", "rust 1.77.2\n")
+			write("mise.toml", "[tools]\nrust = \"1.78.0\"\n")
+			write("rust-toolchain.toml", "[toolchain]\nchannel = \"1.79.0\"\n")
+
+			files, err := rustup.ReadToolchainFiles(appPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(files).To(HaveLen(3))
+			Expect(files[0].Channel).To(Equal("1.79.0"))
+			Expect(files[1].Channel).To(Equal("1.78.0"))
+			Expect(files[2].Channel).To(Equal("1.77.2"))
+		})
+
+		it("skips files that do not request rust", func() {
+			write(".tool-versions", "nodejs 20.11.0\n")
+
+			files, err := rustup.ReadToolchainFiles(appPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(files).To(BeEmpty())
+		})
+	})
+}
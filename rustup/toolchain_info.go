@@ -0,0 +1,113 @@
+/*
+ * Copyright 2018-2024 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rustup
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+const (
+	// ToolchainInfoEnv is the environment variable pointing subsequent buildpacks at the toolchain descriptor
+	ToolchainInfoEnv = "BP_RUST_TOOLCHAIN_INFO"
+
+	// ToolchainInfoFile is the name of the toolchain descriptor written to the Rust layer
+	ToolchainInfoFile = "toolchain.json"
+)
+
+// ToolchainInfo describes the toolchains installed by this buildpack. It is written to `toolchain.json` in the Rust
+// layer so that subsequent buildpacks can read it instead of running `rustc -vV`.
+type ToolchainInfo struct {
+	// DefaultToolchain is the name of the toolchain set as the rustup default, like `stable`
+	DefaultToolchain string `json:"default-toolchain"`
+
+	// Host is the host triple of the default toolchain, like `x86_64-unknown-linux-gnu`
+	Host string `json:"host,omitempty"`
+
+	// RustupHome is the location of $RUSTUP_HOME
+	RustupHome string `json:"rustup-home,omitempty"`
+
+	// CargoHome is the location of $CARGO_HOME
+	CargoHome string `json:"cargo-home,omitempty"`
+
+	// Toolchains are the installed toolchains, in the order they were requested
+	Toolchains []InstalledToolchain `json:"toolchains"`
+}
+
+// InstalledToolchain describes a single installed toolchain
+type InstalledToolchain struct {
+	// Name is the name the toolchain was requested with, like `stable`
+	Name string `json:"name"`
+
+	// Channel is the exact channel the toolchain resolved to, like `1.78.0` or `nightly-2024-05-02`
+	Channel string `json:"channel,omitempty"`
+
+	// Version is the Rust version of the toolchain, like `1.78.0`
+	Version string `json:"version,omitempty"`
+
+	// Path is the location of the toolchain, in $RUSTUP_HOME for installed toolchains
+	Path string `json:"path,omitempty"`
+
+	// Linked is true for custom toolchains linked with `rustup toolchain link`
+	Linked bool `json:"linked,omitempty"`
+
+	// Targets are the target triples the standard library is installed for
+	Targets []string `json:"targets"`
+
+	// Components are the names of the installed components, like `rustc` or `clippy`
+	Components []string `json:"components"`
+}
+
+// Default returns the default toolchain, false if it is not listed
+func (i ToolchainInfo) Default() (InstalledToolchain, bool) {
+	for _, t := range i.Toolchains {
+		if t.Name == i.DefaultToolchain {
+			return t, true
+		}
+	}
+	return InstalledToolchain{}, false
+}
+
+// ReadToolchainInfo reads a toolchain descriptor, like the one $BP_RUST_TOOLCHAIN_INFO points to
+func ReadToolchainInfo(path string) (ToolchainInfo, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return ToolchainInfo{}, fmt.Errorf("unable to read %s\n%w", path, err)
+	}
+
+	var info ToolchainInfo
+	if err := json.Unmarshal(raw, &info); err != nil {
+		return ToolchainInfo{}, fmt.Errorf("unable to parse %s\n%w", path, err)
+	}
+
+	return info, nil
+}
+
+// WriteToolchainInfo writes a toolchain descriptor to the given path
+func WriteToolchainInfo(path string, info ToolchainInfo) error {
+	raw, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to encode toolchain info\n%w", err)
+	}
+
+	if err := os.WriteFile(path, append(raw, '\n'), 0644); err != nil {
+		return fmt.Errorf("unable to write %s\n%w", path, err)
+	}
+
+	return nil
+}
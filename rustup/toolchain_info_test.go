@@ -0,0 +1,102 @@
+/*
+ * Copyright 2018-2024 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rustup_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/paketo-community/rustup/rustup"
+	"github.com/sclevine/spec"
+)
+
+func testToolchainInfo(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		path string
+	)
+
+	it.Before(func() {
+		dir := t.TempDir()
+		path = filepath.Join(dir, "toolchain.json")
+	})
+
+	it("round trips the toolchain info", func() {
+		info := rustup.ToolchainInfo{
+			DefaultToolchain: "stable",
+			Host:             "x86_64-unknown-linux-gnu",
+			RustupHome:       "/layers/rustup",
+			CargoHome:        "/layers/cargo",
+			Toolchains: []rustup.InstalledToolchain{
+				{
+					Name:       "stable",
+					Channel:    "1.78.0",
+					Version:    "1.78.0",
+					Path:       "/layers/rustup/toolchains/stable-x86_64-unknown-linux-gnu",
+					Targets:    []string{"x86_64-unknown-linux-gnu"},
+					Components: []string{"cargo", "rust-std", "rustc"},
+				},
+			},
+		}
+
+		Expect(rustup.WriteToolchainInfo(path, info)).To(Succeed())
+
+		read, err := rustup.ReadToolchainInfo(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(read).To(Equal(info))
+	})
+
+	it("uses stable field names", func() {
+		Expect(os.WriteFile(path, []byte(`{
+  "default-toolchain": "nightly",
+  "host": "aarch64-unknown-linux-musl",
+  "rustup-home": "/layers/rustup",
+  "cargo-home": "/layers/cargo",
+  "toolchains": [
+    {"name": "stable", "version": "1.78.0", "targets": [], "components": []},
+    {"name": "nightly", "channel": "nightly-2024-05-02", "targets": ["wasm32-wasip1"], "components": ["miri"]}
+  ]
+}`), 0644)).To(Succeed())
+
+		info, err := rustup.ReadToolchainInfo(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Host).To(Equal("aarch64-unknown-linux-musl"))
+		Expect(info.RustupHome).To(Equal("/layers/rustup"))
+		Expect(info.CargoHome).To(Equal("/layers/cargo"))
+
+		toolchain, ok := info.Default()
+		Expect(ok).To(BeTrue())
+		Expect(toolchain.Channel).To(Equal("nightly-2024-05-02"))
+		Expect(toolchain.Targets).To(Equal([]string{"wasm32-wasip1"}))
+		Expect(toolchain.Components).To(Equal([]string{"miri"}))
+	})
+
+	it("returns false when the default toolchain is not listed", func() {
+		_, ok := rustup.ToolchainInfo{DefaultToolchain: "stable"}.Default()
+		Expect(ok).To(BeFalse())
+	})
+
+	it("fails on invalid JSON", func() {
+		Expect(os.WriteFile(path, []byte("{"), 0644)).To(Succeed())
+
+		_, err := rustup.ReadToolchainInfo(path)
+		Expect(err).To(MatchError(ContainSubstring("unable to parse")))
+	})
+}
@@ -0,0 +1,100 @@
+/*
+ * Copyright 2018-2024 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rustup_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/paketo-community/rustup/rustup"
+	"github.com/sclevine/spec"
+)
+
+func testToolchain(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+	)
+
+	context("ParseAdditionalToolchains", func() {
+		it("parses an empty list", func() {
+			toolchains, err := rustup.ParseAdditionalToolchains("", "minimal")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(toolchains).To(BeEmpty())
+		})
+
+		it("parses toolchains with settings", func() {
+			toolchains, err := rustup.ParseAdditionalToolchains(
+				"beta nightly-2024-05-01;components=miri,rust-src;targets=wasm32-unknown-unknown;profile=default", "minimal")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(toolchains).To(Equal([]rustup.Toolchain{
+				{Name: "beta", Profile: "minimal"},
+				{
+					Name:       "nightly-2024-05-01",
+					Profile:    "default",
+					Components: []string{"miri", "rust-src"},
+					Targets:    []string{"wasm32-unknown-unknown"},
+				},
+			}))
+		})
+
+		it("merges duplicate toolchains", func() {
+			toolchains, err := rustup.ParseAdditionalToolchains("nightly;components=miri nightly;components=rust-src;profile=complete", "minimal")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(toolchains).To(Equal([]rustup.Toolchain{
+				{Name: "nightly", Profile: "complete", Components: []string{"miri", "rust-src"}, Targets: []string{}},
+			}))
+		})
+
+		it("rejects unknown keys", func() {
+			_, err := rustup.ParseAdditionalToolchains("nightly;foo=bar", "minimal")
+			Expect(err).To(MatchError(ContainSubstring(`unknown key "foo"`)))
+		})
+
+		it("rejects settings without a value", func() {
+			_, err := rustup.ParseAdditionalToolchains("nightly;miri", "minimal")
+			Expect(err).To(MatchError(ContainSubstring(`expected key=value but got "miri"`)))
+		})
+
+		it("rejects a missing channel", func() {
+			_, err := rustup.ParseAdditionalToolchains(";components=miri", "minimal")
+			Expect(err).To(MatchError(ContainSubstring("the channel must be set")))
+		})
+
+		it("rejects invalid targets", func() {
+			_, err := rustup.ParseAdditionalToolchains("nightly;targets=foo", "minimal")
+			Expect(err).To(MatchError(ContainSubstring(`invalid target "foo"`)))
+		})
+
+		it("rejects an invalid profile", func() {
+			_, err := rustup.ParseAdditionalToolchains("nightly;profile=huge", "minimal")
+			Expect(err).To(MatchError(ContainSubstring("profile must be one of")))
+		})
+	})
+
+	context("AppendToolchain", func() {
+		it("does not modify the merged toolchains", func() {
+			a := rustup.Toolchain{Name: "stable", Profile: "minimal", Targets: make([]string, 1, 10)}
+			a.Targets[0] = "wasm32-unknown-unknown"
+
+			toolchains := rustup.AppendToolchain([]rustup.Toolchain{a}, rustup.Toolchain{Name: "stable", Targets: []string{"x86_64-unknown-linux-musl"}})
+			Expect(toolchains).To(HaveLen(1))
+			Expect(toolchains[0].Targets).To(Equal([]string{"wasm32-unknown-unknown", "x86_64-unknown-linux-musl"}))
+			Expect(toolchains[0].Profile).To(Equal("minimal"))
+			Expect(a.Targets).To(Equal([]string{"wasm32-unknown-unknown"}))
+		})
+	})
+}
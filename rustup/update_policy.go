@@ -0,0 +1,126 @@
+/*
+ * Copyright 2018-2024 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rustup
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/heroku/color"
+	"github.com/paketo-buildpacks/libpak"
+	"github.com/paketo-buildpacks/libpak/bard"
+)
+
+const (
+	// UpdatePolicyAlways checks for upstream updates on every build
+	UpdatePolicyAlways = "always"
+
+	// UpdatePolicyNever reuses cached toolchains until the requested toolchains change
+	UpdatePolicyNever = "never"
+
+	// UpdatePolicyInterval reuses cached toolchains until they are older than the policy interval
+	UpdatePolicyInterval = "interval"
+)
+
+// UpdatePolicy controls when cached toolchains are refreshed from upstream
+type UpdatePolicy struct {
+	// Mode is one of UpdatePolicyAlways, UpdatePolicyNever or UpdatePolicyInterval
+	Mode string
+
+	// Interval is the maximum age of cached toolchains for UpdatePolicyInterval
+	Interval time.Duration
+}
+
+// ResolveUpdatePolicy parses $BP_RUST_UPDATE_POLICY. If it is not set, the deprecated $BP_RUST_UPDATE_CHECK is read
+// instead, where `true` maps to `always` and `false` to `never`.
+func ResolveUpdatePolicy(cr libpak.ConfigurationResolver, logger bard.Logger) (UpdatePolicy, error) {
+	val, ok := cr.Resolve("BP_RUST_UPDATE_POLICY")
+	if check, checkSet := cr.Resolve("BP_RUST_UPDATE_CHECK"); checkSet {
+		if ok {
+			logger.Bodyf("%s BP_RUST_UPDATE_CHECK is deprecated and ignored as BP_RUST_UPDATE_POLICY is set",
+				color.YellowString("Warning:"))
+		} else {
+			b, err := strconv.ParseBool(strings.TrimSpace(check))
+			if err != nil {
+				return UpdatePolicy{}, fmt.Errorf("invalid value %q for BP_RUST_UPDATE_CHECK, must be true or false", check)
+			}
+
+			val = UpdatePolicyNever
+			if b {
+				val = UpdatePolicyAlways
+			}
+			logger.Bodyf("%s BP_RUST_UPDATE_CHECK is deprecated, use BP_RUST_UPDATE_POLICY=%s instead",
+				color.YellowString("Warning:"), val)
+		}
+	}
+
+	return ParseUpdatePolicy(val)
+}
+
+var intervalPattern = regexp.MustCompile(`^([0-9]+)([dw])$`)
+
+// ParseUpdatePolicy parses `always`, `never` or an interval. Intervals are Go durations like `12h`, or a number of days
+// or weeks like `7d` or `2w`.
+func ParseUpdatePolicy(val string) (UpdatePolicy, error) {
+	val = strings.TrimSpace(strings.ToLower(val))
+
+	switch val {
+	case "", UpdatePolicyAlways:
+		return UpdatePolicy{Mode: UpdatePolicyAlways}, nil
+	case UpdatePolicyNever:
+		return UpdatePolicy{Mode: UpdatePolicyNever}, nil
+	}
+
+	var interval time.Duration
+	if m := intervalPattern.FindStringSubmatch(val); m != nil {
+		n, err := strconv.Atoi(m[1])
+		if err != nil {
+			return UpdatePolicy{}, fmt.Errorf("invalid update policy %q\n%w", val, err)
+		}
+
+		interval = time.Duration(n) * 24 * time.Hour
+		if m[2] == "w" {
+			interval *= 7
+		}
+	} else {
+		var err error
+		if interval, err = time.ParseDuration(val); err != nil {
+			return UpdatePolicy{}, fmt.Errorf("invalid update policy %q, must be always, never or an interval like 7d", val)
+		}
+	}
+
+	if interval <= 0 {
+		return UpdatePolicy{}, fmt.Errorf("invalid update policy %q, the interval must be positive", val)
+	}
+
+	return UpdatePolicy{Mode: UpdatePolicyInterval, Interval: interval}, nil
+}
+
+// InstalledAt returns the install time to record in layer metadata. The previous install time is kept while it is
+// within the interval, so the cached toolchains are only reinstalled once they are too old.
+func (p UpdatePolicy) InstalledAt(previous interface{}, now time.Time) string {
+	if s, ok := previous.(string); ok {
+		if t, err := time.Parse(time.RFC3339, s); err == nil && !t.After(now) && now.Sub(t) < p.Interval {
+			return s
+		}
+	}
+
+	return now.UTC().Format(time.RFC3339)
+}
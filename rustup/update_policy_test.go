@@ -0,0 +1,136 @@
+/*
+ * Copyright 2018-2024 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rustup_test
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/buildpacks/libcnb"
+	. "github.com/onsi/gomega"
+	"github.com/paketo-buildpacks/libpak"
+	"github.com/paketo-buildpacks/libpak/bard"
+	"github.com/paketo-community/rustup/rustup"
+	"github.com/sclevine/spec"
+)
+
+func testUpdatePolicy(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+	)
+
+	context("ParseUpdatePolicy", func() {
+		it("defaults to always", func() {
+			Expect(rustup.ParseUpdatePolicy("")).To(Equal(rustup.UpdatePolicy{Mode: rustup.UpdatePolicyAlways}))
+			Expect(rustup.ParseUpdatePolicy("always")).To(Equal(rustup.UpdatePolicy{Mode: rustup.UpdatePolicyAlways}))
+		})
+
+		it("parses never", func() {
+			Expect(rustup.ParseUpdatePolicy("never")).To(Equal(rustup.UpdatePolicy{Mode: rustup.UpdatePolicyNever}))
+		})
+
+		it("parses intervals", func() {
+			Expect(rustup.ParseUpdatePolicy("7d")).To(Equal(rustup.UpdatePolicy{Mode: rustup.UpdatePolicyInterval, Interval: 7 * 24 * time.Hour}))
+			Expect(rustup.ParseUpdatePolicy("2w")).To(Equal(rustup.UpdatePolicy{Mode: rustup.UpdatePolicyInterval, Interval: 14 * 24 * time.Hour}))
+			Expect(rustup.ParseUpdatePolicy("12h")).To(Equal(rustup.UpdatePolicy{Mode: rustup.UpdatePolicyInterval, Interval: 12 * time.Hour}))
+		})
+
+		it("rejects invalid policies", func() {
+			_, err := rustup.ParseUpdatePolicy("sometimes")
+			Expect(err).To(MatchError(ContainSubstring("invalid update policy \"sometimes\"")))
+
+			_, err = rustup.ParseUpdatePolicy("0d")
+			Expect(err).To(MatchError(ContainSubstring("the interval must be positive")))
+		})
+	})
+
+	context("ResolveUpdatePolicy", func() {
+		var (
+			buf    *bytes.Buffer
+			cr     libpak.ConfigurationResolver
+			logger bard.Logger
+		)
+
+		it.Before(func() {
+			var err error
+			cr, err = libpak.NewConfigurationResolver(libcnb.Buildpack{}, nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			buf = &bytes.Buffer{}
+			logger = bard.NewLogger(buf)
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_RUST_UPDATE_POLICY")).To(Succeed())
+			Expect(os.Unsetenv("BP_RUST_UPDATE_CHECK")).To(Succeed())
+		})
+
+		it("reads BP_RUST_UPDATE_POLICY", func() {
+			Expect(os.Setenv("BP_RUST_UPDATE_POLICY", "never")).To(Succeed())
+
+			Expect(rustup.ResolveUpdatePolicy(cr, logger)).To(Equal(rustup.UpdatePolicy{Mode: rustup.UpdatePolicyNever}))
+			Expect(buf.String()).To(BeEmpty())
+		})
+
+		it("maps the deprecated BP_RUST_UPDATE_CHECK", func() {
+			Expect(os.Setenv("BP_RUST_UPDATE_CHECK", "false")).To(Succeed())
+			Expect(rustup.ResolveUpdatePolicy(cr, logger)).To(Equal(rustup.UpdatePolicy{Mode: rustup.UpdatePolicyNever}))
+			Expect(buf.String()).To(ContainSubstring("BP_RUST_UPDATE_CHECK is deprecated, use BP_RUST_UPDATE_POLICY=never instead"))
+
+			Expect(os.Setenv("BP_RUST_UPDATE_CHECK", "true")).To(Succeed())
+			Expect(rustup.ResolveUpdatePolicy(cr, logger)).To(Equal(rustup.UpdatePolicy{Mode: rustup.UpdatePolicyAlways}))
+		})
+
+		it("prefers BP_RUST_UPDATE_POLICY over BP_RUST_UPDATE_CHECK", func() {
+			Expect(os.Setenv("BP_RUST_UPDATE_POLICY", "7d")).To(Succeed())
+			Expect(os.Setenv("BP_RUST_UPDATE_CHECK", "false")).To(Succeed())
+
+			Expect(rustup.ResolveUpdatePolicy(cr, logger)).To(Equal(rustup.UpdatePolicy{Mode: rustup.UpdatePolicyInterval, Interval: 7 * 24 * time.Hour}))
+			Expect(buf.String()).To(ContainSubstring("BP_RUST_UPDATE_CHECK is deprecated and ignored"))
+		})
+
+		it("rejects an invalid BP_RUST_UPDATE_CHECK", func() {
+			Expect(os.Setenv("BP_RUST_UPDATE_CHECK", "maybe")).To(Succeed())
+
+			_, err := rustup.ResolveUpdatePolicy(cr, logger)
+			Expect(err).To(MatchError(ContainSubstring("invalid value \"maybe\" for BP_RUST_UPDATE_CHECK")))
+		})
+	})
+
+	context("InstalledAt", func() {
+		var (
+			now    = time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+			policy = rustup.UpdatePolicy{Mode: rustup.UpdatePolicyInterval, Interval: 7 * 24 * time.Hour}
+		)
+
+		it("keeps a previous install time within the interval", func() {
+			Expect(policy.InstalledAt("2024-05-05T12:00:00Z", now)).To(Equal("2024-05-05T12:00:00Z"))
+		})
+
+		it("returns now once the interval has passed", func() {
+			Expect(policy.InstalledAt("2024-05-01T12:00:00Z", now)).To(Equal("2024-05-10T12:00:00Z"))
+		})
+
+		it("returns now without a valid previous install time", func() {
+			Expect(policy.InstalledAt(nil, now)).To(Equal("2024-05-10T12:00:00Z"))
+			Expect(policy.InstalledAt("yesterday", now)).To(Equal("2024-05-10T12:00:00Z"))
+			Expect(policy.InstalledAt("2024-06-01T12:00:00Z", now)).To(Equal("2024-05-10T12:00:00Z"))
+		})
+	})
+}
@@ -0,0 +1,141 @@
+/*
+ * Copyright 2018-2024 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rustup
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// RustcVersion is the verbose version information printed by `rustc -vV`
+type RustcVersion struct {
+	// Release is the version of the compiler, like `1.78.0` or `1.80.0-nightly`
+	Release string
+
+	// CommitHash is the commit of rust-lang/rust the compiler was built from, empty if unknown
+	CommitHash string
+
+	// CommitDate is the date of the commit, empty if unknown
+	CommitDate string
+
+	// Host is the target triple the compiler runs on
+	Host string
+
+	// LLVMVersion is the version of LLVM the compiler was built with, empty if unknown
+	LLVMVersion string
+}
+
+// ParseRustcVersion parses the output of `rustc -vV`
+func ParseRustcVersion(output string) (RustcVersion, error) {
+	var v RustcVersion
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+
+		value = strings.TrimSpace(value)
+		if value == "unknown" {
+			value = ""
+		}
+
+		switch strings.TrimSpace(key) {
+		case "release":
+			v.Release = value
+		case "commit-hash":
+			v.CommitHash = value
+		case "commit-date":
+			v.CommitDate = value
+		case "host":
+			v.Host = value
+		case "LLVM version":
+			v.LLVMVersion = value
+		}
+	}
+
+	if v.Release == "" {
+		return RustcVersion{}, fmt.Errorf("unable to find release in rustc version output %q", strings.TrimSpace(output))
+	}
+
+	return v, nil
+}
+
+// RustupVersion is the version information printed by `rustup --version`
+type RustupVersion struct {
+	// Version is the version of rustup, like `1.27.1`
+	Version string
+
+	// CommitHash is the abbreviated commit of rust-lang/rustup, empty if not printed
+	CommitHash string
+
+	// CommitDate is the date of the commit, empty if not printed
+	CommitDate string
+}
+
+var datePattern = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+
+// ParseRustupVersion parses the output of `rustup --version`, like `rustup 1.27.1 (54dd3d00f 2024-04-24)`. Older
+// versions of rustup print only the date in parentheses, and all versions print info lines about rustc after it.
+func ParseRustupVersion(output string) (RustupVersion, error) {
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "rustup" {
+			continue
+		}
+
+		v := RustupVersion{Version: fields[1]}
+		for _, f := range fields[2:] {
+			f = strings.Trim(f, "()")
+			if datePattern.MatchString(f) {
+				v.CommitDate = f
+			} else if f != "" {
+				v.CommitHash = f
+			}
+		}
+
+		return v, nil
+	}
+
+	return RustupVersion{}, fmt.Errorf("unable to find version in rustup version output %q", strings.TrimSpace(output))
+}
+
+// purlQualifiers formats PURL qualifiers sorted by key, skipping empty values
+func purlQualifiers(qualifiers map[string]string) string {
+	var keys []string
+	for k, v := range qualifiers {
+		if v != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var s []string
+	for _, k := range keys {
+		s = append(s, fmt.Sprintf("%s=%s", k, qualifiers[k]))
+	}
+
+	if len(s) == 0 {
+		return ""
+	}
+	return "?" + strings.Join(s, "&")
+}
@@ -0,0 +1,97 @@
+/*
+ * Copyright 2018-2024 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rustup_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/paketo-community/rustup/rustup"
+	"github.com/sclevine/spec"
+)
+
+func testVersion(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+	)
+
+	context("ParseRustcVersion", func() {
+		it("parses verbose output", func() {
+			v, err := rustup.ParseRustcVersion(`rustc 1.78.0 (9b00956e5 2024-04-29)
+binary: rustc
+commit-hash: 9b00956e56009bab2aa15d7bff10916599e3d6d6
+commit-date: 2024-04-29
+host: x86_64-unknown-linux-gnu
+release: 1.78.0
+LLVM version: 18.1.2
+`)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(v).To(Equal(rustup.RustcVersion{
+				Release:     "1.78.0",
+				CommitHash:  "9b00956e56009bab2aa15d7bff10916599e3d6d6",
+				CommitDate:  "2024-04-29",
+				Host:        "x86_64-unknown-linux-gnu",
+				LLVMVersion: "18.1.2",
+			}))
+		})
+
+		it("treats unknown values as empty", func() {
+			v, err := rustup.ParseRustcVersion(`rustc 1.78.0
+binary: rustc
+commit-hash: unknown
+commit-date: unknown
+host: x86_64-unknown-linux-musl
+release: 1.78.0
+`)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(v.Release).To(Equal("1.78.0"))
+			Expect(v.CommitHash).To(BeEmpty())
+			Expect(v.CommitDate).To(BeEmpty())
+			Expect(v.LLVMVersion).To(BeEmpty())
+		})
+
+		it("fails without a release", func() {
+			_, err := rustup.ParseRustcVersion("rustc\n")
+			Expect(err).To(MatchError(ContainSubstring("unable to find release")))
+		})
+	})
+
+	context("ParseRustupVersion", func() {
+		it("parses the version, commit and date", func() {
+			v, err := rustup.ParseRustupVersion("rustup 1.27.1 (54dd3d00f 2024-04-24)\ninfo: This is the version for the rustup toolchain manager, not the rustc compiler.\n")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(v).To(Equal(rustup.RustupVersion{Version: "1.27.1", CommitHash: "54dd3d00f", CommitDate: "2024-04-24"}))
+		})
+
+		it("parses output without a commit", func() {
+			v, err := rustup.ParseRustupVersion("rustup 1.24.3 (2021-05-31)")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(v).To(Equal(rustup.RustupVersion{Version: "1.24.3", CommitDate: "2021-05-31"}))
+		})
+
+		it("skips leading info lines", func() {
+			v, err := rustup.ParseRustupVersion("info: some warning\nrustup 1.28.0")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(v.Version).To(Equal("1.28.0"))
+		})
+
+		it("fails without a version", func() {
+			_, err := rustup.ParseRustupVersion("rustup")
+			Expect(err).To(MatchError(ContainSubstring("unable to find version")))
+		})
+	})
+}